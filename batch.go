@@ -0,0 +1,340 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+// A batch, as encoded by Batch.MarshalBinary, is laid out as follows,
+// where every integer is encoded as an unsigned varint:
+//
+//	for each operation, in the order in which it was added:
+//	  kind      1 byte    0 for Put, 1 for Del, 2 for Cut, 3 for Compact,
+//	                      4 for CutRange, 5 for DelRange, and 6 for a
+//	                      tombstone imported from a delta
+//	  ver       varint    the version, except for Cut and CutRange
+//	  klen      varint    the length of the key, or the start of the
+//	                      range, except for Compact
+//	  key       klen      the key, or the start of the range
+//	  vlen      varint    the length of the value, or the end of the
+//	                      range, plus one, or 0 for nil, for Put,
+//	                      CutRange and DelRange only
+//	  val       vlen-1    the value, or the end of the range
+//
+// Prefix operations are recorded as the equivalent range operations.
+
+// ErrBatchFormat is returned when decoding or applying a batch which
+// is malformed, or which contains an unknown operation.
+var ErrBatchFormat = errors.New("vtree: invalid batch format")
+
+type op[V any] struct {
+	kind byte
+	ver  uint64
+	key  []byte
+	end  []byte
+	val  V
+}
+
+const (
+	opPut byte = iota
+	opDel
+	opCut
+	opCompact
+	opCutRange
+	opDelRange
+	opTomb
+)
+
+// Batch records a sequence of operations which can be applied to a
+// Copy in a single step, and which can be encoded to be shipped to
+// another process, or logged. The keys and values which are added
+// must not be modified afterwards. The zero value is an empty batch,
+// ready to use. A Batch is not thread safe. Only batches of []byte
+// values can be encoded, with other types returning ErrValueType.
+type Batch[V any] struct {
+	ops []*op[V]
+}
+
+// Put records the insertion of a value at a specific version.
+func (b *Batch[V]) Put(ver uint64, key []byte, val V) {
+	b.put(ver, key, val)
+}
+
+// Del records the deletion of a key at a specific version.
+func (b *Batch[V]) Del(ver uint64, key []byte) {
+	b.add(opDel, ver, key, nil)
+}
+
+// Cut records the removal of a key along with all of its versions.
+func (b *Batch[V]) Cut(key []byte) {
+	b.add(opCut, 0, key, nil)
+}
+
+// CutPrefix records the removal of every key with the given prefix.
+func (b *Batch[V]) CutPrefix(prefix []byte) {
+	b.add(opCutRange, 0, prefix, after(prefix))
+}
+
+// Len returns the number of operations in the batch.
+func (b *Batch[V]) Len() int {
+	return len(b.ops)
+}
+
+// Reset removes all of the operations from the batch, so that it can
+// be reused.
+func (b *Batch[V]) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// Validate checks that every operation in the batch is well formed.
+func (b *Batch[V]) Validate() error {
+	for _, o := range b.ops {
+		if o.kind > opTomb {
+			return ErrBatchFormat
+		}
+		if o.kind == opCutRange || o.kind == opDelRange {
+			if o.end != nil && bytes.Compare(o.key, o.end) > 0 {
+				return ErrBatchFormat
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalBinary encodes the batch into a binary form.
+func (b *Batch[V]) MarshalBinary() ([]byte, error) {
+	return b.encode(nil)
+}
+
+// UnmarshalBinary replaces the contents of the batch with a batch
+// decoded from the binary form produced by MarshalBinary.
+func (b *Batch[V]) UnmarshalBinary(data []byte) error {
+	return b.decode(append([]byte(nil), data...))
+}
+
+// Apply validates the batch, and then applies all of its operations to
+// the copy. Runs of Put, Del and Cut operations are applied in order
+// of key, so that the nodes along shared paths are visited together,
+// while the order of operations on any single key is preserved. If
+// the batch is invalid then the copy is not modified.
+func (c *Copy[V]) Apply(b *Batch[V]) error {
+
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	ops := make([]*op[V], len(b.ops))
+	copy(ops, b.ops)
+
+	for i := 0; i < len(ops); {
+		j := i
+		for j < len(ops) && ops[j].kind <= opCut {
+			j++
+		}
+		run := ops[i:j]
+		sort.SliceStable(run, func(x, y int) bool {
+			return bytes.Compare(run[x].key, run[y].key) < 0
+		})
+		if j == i {
+			j++
+		}
+		i = j
+	}
+
+	for _, o := range ops {
+		o.apply(c)
+	}
+
+	return nil
+
+}
+
+// ---------------------------------------------------------------------------
+
+func (b *Batch[V]) add(kind byte, ver uint64, key, end []byte) {
+	if b != nil {
+		b.ops = append(b.ops, &op[V]{kind: kind, ver: ver, key: key, end: end})
+	}
+}
+
+func (b *Batch[V]) put(ver uint64, key []byte, val V) {
+	if b != nil {
+		b.ops = append(b.ops, &op[V]{kind: opPut, ver: ver, key: key, val: val})
+	}
+}
+
+func (b *Batch[V]) apply(c *Copy[V]) {
+	for _, o := range b.ops {
+		o.apply(c)
+	}
+}
+
+func (o *op[V]) apply(c *Copy[V]) {
+	switch o.kind {
+	case opPut:
+		c.Put(o.ver, o.key, o.val)
+	case opDel:
+		c.Del(o.ver, o.key)
+	case opCut:
+		c.Cut(o.key)
+	case opCompact:
+		c.Compact(o.ver)
+	case opCutRange:
+		c.CutRange(o.key, o.end)
+	case opDelRange:
+		c.DelRange(o.ver, o.key, o.end)
+	case opTomb:
+		c.tomb(o.ver, o.key)
+	}
+}
+
+func hasVer(kind byte) bool {
+	return kind != opCut && kind != opCutRange
+}
+
+func hasKey(kind byte) bool {
+	return kind != opCompact
+}
+
+func hasVal(kind byte) bool {
+	return kind == opPut || kind == opCutRange || kind == opDelRange
+}
+
+// encode appends the binary form of the batch to the buffer.
+func (b *Batch[V]) encode(buf []byte) ([]byte, error) {
+	for _, o := range b.ops {
+		buf = append(buf, o.kind)
+		if hasVer(o.kind) {
+			buf = appendUvarint(buf, o.ver)
+		}
+		if hasKey(o.kind) {
+			buf = appendUvarint(buf, uint64(len(o.key)))
+			buf = append(buf, o.key...)
+		}
+		if hasVal(o.kind) {
+			val := o.end
+			if o.kind == opPut {
+				var ok bool
+				if val, ok = bytesOf(o.val); !ok {
+					return nil, ErrValueType
+				}
+			}
+			if val == nil {
+				buf = appendUvarint(buf, 0)
+			} else {
+				buf = appendUvarint(buf, uint64(len(val))+1)
+				buf = append(buf, val...)
+			}
+		}
+	}
+	return buf, nil
+}
+
+// decode replaces the contents of the batch with the operations in
+// the buffer, referencing the keys and values without copying them.
+func (b *Batch[V]) decode(buf []byte) error {
+
+	r := bytes.NewReader(buf)
+
+	b.ops = b.ops[:0]
+
+	for r.Len() > 0 {
+		o, err := decodeOp[V](r, buf)
+		if err == ErrValueType {
+			b.ops = b.ops[:0]
+			return err
+		}
+		if err != nil {
+			b.ops = b.ops[:0]
+			return ErrBatchFormat
+		}
+		b.ops = append(b.ops, o)
+	}
+
+	return nil
+
+}
+
+func decodeOp[V any](b *bytes.Reader, buf []byte) (*op[V], error) {
+
+	var err error
+
+	o := &op[V]{}
+
+	if o.kind, err = b.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	if o.kind > opTomb {
+		return nil, ErrBatchFormat
+	}
+
+	if hasVer(o.kind) {
+		if o.ver, err = binary.ReadUvarint(b); err != nil {
+			return nil, err
+		}
+	}
+
+	if hasKey(o.kind) {
+		if o.key, err = slice(b, buf, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if hasVal(o.kind) {
+		if o.end, err = slice(b, buf, 1); err != nil {
+			return nil, err
+		}
+		if o.kind == opPut {
+			var ok bool
+			if o.val, ok = valueOf[V](o.end); !ok {
+				return nil, ErrValueType
+			}
+			o.end = nil
+		}
+	}
+
+	return o, nil
+
+}
+
+// slice reads a length prefixed byte slice from the reader, without
+// copying, where a length below the offset represents a nil slice.
+func slice(b *bytes.Reader, buf []byte, off uint64) ([]byte, error) {
+	n, err := binary.ReadUvarint(b)
+	if err != nil {
+		return nil, err
+	}
+	if n < off {
+		return nil, nil
+	}
+	n -= off
+	pos := uint64(len(buf) - b.Len())
+	if n > uint64(b.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b.Seek(int64(n), io.SeekCurrent)
+	return buf[pos : pos+n : pos+n], nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(b, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
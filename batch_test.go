@@ -0,0 +1,141 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBatch(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+
+	Convey("Can apply a batch the same as applying each operation", t, func() {
+		for round := 0; round < 50; round++ {
+			b := &Batch[[]byte]{}
+			c := New[[]byte]().Copy()
+			for _, v := range s {
+				c.Put(1, []byte(v), []byte(v))
+			}
+			base := c.Tree()
+			for op := 0; op < 100; op++ {
+				k := []byte(s[r.Intn(len(s))])
+				v := uint64(r.Intn(10))
+				switch r.Intn(10) {
+				case 0:
+					b.Cut(k)
+					c.Cut(k)
+				case 1:
+					b.Del(v, k)
+					c.Del(v, k)
+				case 2:
+					p := k[:r.Intn(len(k))]
+					b.CutPrefix(p)
+					c.CutPrefix(p)
+				case 3:
+					b.Put(v, k, nil)
+					c.Put(v, k, nil)
+				default:
+					x := []byte(fmt.Sprint(round, op))
+					b.Put(v, k, x)
+					c.Put(v, k, x)
+				}
+			}
+			So(b.Len(), ShouldEqual, 100)
+			a := base.Copy()
+			So(a.Apply(b), ShouldBeNil)
+			So(a.Size(), ShouldEqual, c.Size())
+			So(dump(a.Tree()), ShouldResemble, dump(c.Tree()))
+			data, err := b.MarshalBinary()
+			So(err, ShouldBeNil)
+			d := &Batch[[]byte]{}
+			So(d.UnmarshalBinary(data), ShouldBeNil)
+			So(d.Len(), ShouldEqual, 100)
+			a = base.Copy()
+			So(a.Apply(d), ShouldBeNil)
+			So(dump(a.Tree()), ShouldResemble, dump(c.Tree()))
+		}
+	})
+
+	Convey("Can encode and decode a batch", t, func() {
+		b := &Batch[[]byte]{}
+		b.Put(1, []byte("/test"), []byte("ONE"))
+		b.Put(2, []byte("/test"), nil)
+		b.Put(3, []byte(""), []byte{})
+		b.Del(4, []byte("/test"))
+		b.Cut([]byte("/cut"))
+		b.CutPrefix([]byte("/pre"))
+		b.CutPrefix(nil)
+		data, err := b.MarshalBinary()
+		So(err, ShouldBeNil)
+		d := &Batch[[]byte]{}
+		d.Put(9, []byte("/old"), nil)
+		So(d.UnmarshalBinary(data), ShouldBeNil)
+		data[3] = 'X'
+		So(d.ops, ShouldResemble, []*op[[]byte]{
+			{kind: opPut, ver: 1, key: []byte("/test"), val: []byte("ONE")},
+			{kind: opPut, ver: 2, key: []byte("/test")},
+			{kind: opPut, ver: 3, key: []byte(""), val: []byte{}},
+			{kind: opDel, ver: 4, key: []byte("/test")},
+			{kind: opCut, key: []byte("/cut")},
+			{kind: opCutRange, key: []byte("/pre"), end: []byte("/prf")},
+			{kind: opCutRange, key: []byte("")},
+		})
+		d.Reset()
+		So(d.Len(), ShouldEqual, 0)
+	})
+
+	Convey("Can not decode a malformed batch", t, func() {
+		b := &Batch[[]byte]{}
+		b.Put(1, []byte("/test"), []byte("ONE"))
+		data, _ := b.MarshalBinary()
+		d := &Batch[[]byte]{}
+		So(d.UnmarshalBinary(data[:len(data)-1]), ShouldEqual, ErrBatchFormat)
+		So(d.UnmarshalBinary([]byte{99}), ShouldEqual, ErrBatchFormat)
+		So(d.Len(), ShouldEqual, 0)
+	})
+
+	Convey("Can not apply an invalid batch", t, func() {
+		c := New[[]byte]().Copy()
+		b := &Batch[[]byte]{}
+		b.Put(1, []byte("/test"), []byte("ONE"))
+		b.add(opCutRange, 0, []byte("/b"), []byte("/a"))
+		So(c.Apply(b), ShouldEqual, ErrBatchFormat)
+		So(c.Size(), ShouldEqual, 0)
+	})
+
+	Convey("Can apply a batch within a database update", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		b := &Batch[[]byte]{}
+		b.Put(1, []byte("/b"), []byte("B"))
+		b.Put(1, []byte("/a"), []byte("A"))
+		b.CutPrefix([]byte("/b"))
+		So(db.Update(func(c *Copy[[]byte]) error {
+			return c.Apply(b)
+		}), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(dump(db.Load()), ShouldResemble, []string{`/a@1="A"/false/false`})
+		So(db.Close(), ShouldBeNil)
+	})
+
+}
@@ -0,0 +1,145 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrUnsorted is returned when adding a key to a Builder which is
+// not greater than the key which was previously added.
+var ErrUnsorted = errors.New("vtree: keys are not in ascending order")
+
+// Builder constructs a tree from keys which are added in ascending
+// order. Nodes are built bottom-up along the rightmost path of the
+// tree, so that each node is allocated exactly once, without any of
+// the intermediate copies made when inserting with a Copy. The keys
+// and values which are added must not be modified afterwards. A
+// Builder is not thread safe.
+type Builder[V any] struct {
+	size  int
+	last  []byte
+	clock uint64
+	stack []*frame[V]
+}
+
+// frame is a node on the rightmost path of the tree being built,
+// along with the length of the key which leads to the end of it.
+type frame[V any] struct {
+	node  *Node[V]
+	depth int
+}
+
+// NewBuilder returns a new Builder for constructing a tree.
+func NewBuilder[V any]() *Builder[V] {
+	return &Builder[V]{
+		stack: []*frame[V]{{node: &Node[V]{}}},
+	}
+}
+
+// Add adds a key, along with all of its versions, to the tree. The
+// key must be greater than every key which was previously added,
+// otherwise ErrUnsorted is returned and the key is not added.
+func (b *Builder[V]) Add(key []byte, val *Item[V]) error {
+
+	if b.size > 0 && bytes.Compare(key, b.last) <= 0 {
+		return ErrUnsorted
+	}
+
+	l := prefix(b.last, key)
+
+	// Close any nodes below the common prefix
+	var p *frame[V]
+	for b.top().depth > l {
+		p, b.stack = b.top(), b.stack[:len(b.stack)-1]
+		p.node.count()
+	}
+
+	// Split the closed node at the common prefix
+	if t := b.top(); t.depth < l {
+		m := &Node[V]{prefix: p.node.prefix[:l-t.depth]}
+		p.node.prefix = p.node.prefix[l-t.depth:]
+		m.edges = []*Node[V]{p.node}
+		t.node.edges[len(t.node.edges)-1] = m
+		b.stack = append(b.stack, &frame[V]{node: m, depth: l})
+	}
+
+	if len(key) == l {
+		// Only the empty key ends at the root
+		b.top().node.leaf = &leaf[V]{key: key, val: val}
+	} else {
+		n := &Node[V]{
+			leaf:   &leaf[V]{key: key, val: val},
+			prefix: key[l:],
+		}
+		t := b.top()
+		t.node.edges = append(t.node.edges, n)
+		b.stack = append(b.stack, &frame[V]{node: n, depth: len(key)})
+	}
+
+	b.size++
+
+	b.last = key
+
+	if m := val.list.max(); m != nil {
+		b.clock = max(b.clock, m.ver)
+	}
+
+	return nil
+
+}
+
+// Put adds a value with the specified version number to the tree. The
+// key must be greater than or equal to the key which was previously
+// added, otherwise ErrUnsorted is returned and the value is not added.
+// Successive values for the same key are added as further versions.
+func (b *Builder[V]) Put(ver uint64, key []byte, val V) error {
+
+	if b.size > 0 && bytes.Equal(key, b.last) {
+		l := b.top().node.leaf
+		l.val = l.val.put(ver, val)
+		b.clock = max(b.clock, ver)
+		return nil
+	}
+
+	return b.Add(key, newItem[V]().put(ver, val))
+
+}
+
+// Size returns the number of keys which have been added.
+func (b *Builder[V]) Size() int {
+	return b.size
+}
+
+// Tree completes the tree, and returns it. The Builder is then reset,
+// so that it can be used to construct another tree.
+func (b *Builder[V]) Tree() *Tree[V] {
+
+	for i := len(b.stack) - 1; i >= 0; i-- {
+		b.stack[i].node.count()
+	}
+
+	t := &Tree[V]{size: b.size, root: b.stack[0].node, clock: b.clock}
+
+	*b = *NewBuilder[V]()
+
+	return t
+
+}
+
+func (b *Builder[V]) top() *frame[V] {
+	return b.stack[len(b.stack)-1]
+}
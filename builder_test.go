@@ -0,0 +1,139 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func shape(n *Node[[]byte]) string {
+	var b strings.Builder
+	var f func(n *Node[[]byte], d int)
+	f = func(n *Node[[]byte], d int) {
+		fmt.Fprintf(&b, "%s%q %d", strings.Repeat(" ", d), n.prefix, n.size)
+		if n.isLeaf() {
+			fmt.Fprintf(&b, " %q", n.leaf.key)
+		}
+		b.WriteString("\n")
+		for _, e := range n.edges {
+			f(e, d+1)
+		}
+	}
+	f(n, 0)
+	return b.String()
+}
+
+func TestBuilder(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+
+	Convey("Can build trees identical to those built with Put", t, func() {
+		for i := 0; i < 200; i++ {
+			set := map[string]bool{}
+			for j := r.Intn(60); j >= 0; j-- {
+				k := make([]byte, r.Intn(6))
+				for x := range k {
+					k[x] = "/ab"[r.Intn(3)]
+				}
+				set[string(k)] = true
+			}
+			var keys []string
+			for k := range set {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			c := New[[]byte]().Copy()
+			b := NewBuilder[[]byte]()
+			for _, k := range keys {
+				c.Put(1, []byte(k), []byte(k))
+				So(b.Put(1, []byte(k), []byte(k)), ShouldBeNil)
+			}
+			So(b.Size(), ShouldEqual, len(keys))
+			tree := b.Tree()
+			So(tree.Size(), ShouldEqual, c.Size())
+			So(shape(tree.root), ShouldEqual, shape(c.Root()))
+			So(sized(tree.root), ShouldBeTrue)
+			So(dump(tree), ShouldResemble, dump(c.Tree()))
+		}
+	})
+
+	Convey("Can add multiple versions of each key", t, func() {
+		b := NewBuilder[[]byte]()
+		So(b.Put(1, []byte(""), []byte("ROOT")), ShouldBeNil)
+		for _, v := range s {
+			So(b.Put(1, []byte(v), []byte("ONE")), ShouldBeNil)
+			So(b.Put(2, []byte(v), []byte("TWO")), ShouldBeNil)
+		}
+		i, _ := newItem[[]byte]().put(1, []byte("ONE")).del(3)
+		So(b.Add([]byte("/zzz"), i), ShouldBeNil)
+		tree := b.Tree()
+		c := tree.Copy()
+		So(c.Size(), ShouldEqual, len(s)+2)
+		So(c.Get(1, []byte("")), ShouldResemble, []byte("ROOT"))
+		So(c.Get(1, []byte(s[7])), ShouldResemble, []byte("ONE"))
+		So(c.Get(2, []byte(s[7])), ShouldResemble, []byte("TWO"))
+		So(c.Get(3, []byte("/zzz")), ShouldBeNil)
+		So(c.Get(2, []byte("/zzz")), ShouldResemble, []byte("ONE"))
+		So(c.Put(3, []byte(s[7]), []byte("TRI")), ShouldResemble, []byte("TWO"))
+		So(c.Cut([]byte(s[8])), ShouldResemble, []byte("TWO"))
+		So(tree.Copy().Get(3, []byte(s[7])), ShouldResemble, []byte("TWO"))
+		So(tree.Copy().Get(3, []byte(s[8])), ShouldResemble, []byte("TWO"))
+	})
+
+	Convey("Can add versions without changing the added items", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/a"), []byte("A"))
+		c.Put(1, []byte("/b"), []byte("B"))
+		src := c.Tree()
+		b := NewBuilder[[]byte]()
+		for k, i := range src.All() {
+			So(b.Add(k, i), ShouldBeNil)
+		}
+		So(b.Put(2, []byte("/b"), []byte("TWO")), ShouldBeNil)
+		tree := b.Tree()
+		So(tree.Copy().Get(2, []byte("/b")), ShouldResemble, []byte("TWO"))
+		So(src.Copy().Get(2, []byte("/b")), ShouldResemble, []byte("B"))
+		So(dump(src), ShouldResemble, []string{`/a@1="A"/false/false`, `/b@1="B"/false/false`})
+	})
+
+	Convey("Can not add keys out of order", t, func() {
+		b := NewBuilder[[]byte]()
+		So(b.Put(1, []byte("/b"), []byte("B")), ShouldBeNil)
+		So(b.Put(1, []byte("/a"), []byte("A")), ShouldEqual, ErrUnsorted)
+		So(b.Add([]byte("/b"), newItem[[]byte]()), ShouldEqual, ErrUnsorted)
+		So(b.Put(1, []byte("/c"), []byte("C")), ShouldBeNil)
+		So(b.Put(1, []byte(""), []byte("ROOT")), ShouldEqual, ErrUnsorted)
+		tree := b.Tree()
+		So(tree.Size(), ShouldEqual, 2)
+		So(dump(tree), ShouldResemble, []string{`/b@1="B"/false/false`, `/c@1="C"/false/false`})
+	})
+
+	Convey("Can reuse a builder once the tree is built", t, func() {
+		b := NewBuilder[[]byte]()
+		So(b.Put(1, []byte("/b"), []byte("B")), ShouldBeNil)
+		b.Tree()
+		So(b.Put(1, []byte("/a"), []byte("A")), ShouldBeNil)
+		tree := b.Tree()
+		So(tree.Size(), ShouldEqual, 1)
+		So(New[[]byte]().Size(), ShouldEqual, NewBuilder[[]byte]().Tree().Size())
+	})
+
+}
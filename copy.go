@@ -16,76 +16,327 @@ package vtree
 
 import (
 	"bytes"
+	"sync/atomic"
 )
 
+// gens is used to assign a unique generation to each Copy.
+var gens uint64
+
 // Copy is a copy of a tree which can be used to apply changes to
 // the radix tree. All changes are applied atomically and a new tree
 // is returned when committed. A Copy is not thread safe.
-type Copy struct {
-	size int
-	root *Node
+type Copy[V any] struct {
+	size   int
+	root   *Node[V]
+	clock  uint64
+	gone   *removal
+	gen    uint64
+	log    *Batch[V]
+	saves  []Savepoint[V]
+	parent *Copy[V]
+	base   *Node[V]
+	done   bool
+	track  bool
+	feed   []Change[V]
 }
 
 // Size is used to return the total number of elements in the tree.
-func (c *Copy) Size() int {
+func (c *Copy[V]) Size() int {
 	return c.size
 }
 
 // Root returns the root of the radix tree within this tree copy.
-func (c *Copy) Root() *Node {
+// Subsequent changes to the copy do not modify the returned node.
+func (c *Copy[V]) Root() *Node[V] {
+	c.seal()
 	return c.root
 }
 
 // Tree returns a new tree with the changes committed in memory.
-func (c *Copy) Tree() *Tree {
-	return &Tree{c.size, c.root}
+// Subsequent changes to the copy do not modify the returned tree.
+func (c *Copy[V]) Tree() *Tree[V] {
+	c.seal()
+	return &Tree[V]{size: c.size, root: c.root, clock: c.clock, gone: c.gone}
 }
 
 // Cursor returns a new cursor for iterating through the radix tree.
-func (c *Copy) Cursor() *Cursor {
-	return &Cursor{tree: c}
+func (c *Copy[V]) Cursor() *Cursor[V] {
+	return &Cursor[V]{tree: c}
+}
+
+// PrefixCursor returns a new cursor for iterating through only those
+// keys in the radix tree which begin with the specified prefix.
+func (c *Copy[V]) PrefixCursor(prefix []byte) *Cursor[V] {
+	if prefix == nil {
+		prefix = []byte{}
+	}
+	return &Cursor[V]{tree: c, pre: prefix}
 }
 
 // Get is used to retrieve a specific key, returning the current value.
-func (c *Copy) Get(ver uint64, key []byte) []byte {
-	if val := c.root.get(key); val != nil {
-		return val.Get(ver)
+func (c *Copy[V]) Get(ver uint64, key []byte) (val V) {
+	if i := c.root.get(key); i != nil {
+		return i.Get(ver)
 	}
-	return nil
+	return
+}
+
+// Lookup is used to retrieve a specific key, returning the value at the
+// specified version, and whether a value is visible at that version, so
+// that a stored zero value can be told apart from a missing key.
+func (c *Copy[V]) Lookup(ver uint64, key []byte) (val V, ok bool) {
+	if i := c.root.get(key); i != nil && i.live(ver) {
+		return i.Get(ver), true
+	}
+	return
+}
+
+// LongestPrefix returns the key and value of the longest key in the
+// tree which is a prefix of the specified key, including the key itself.
+// If no such key exists, then ok is false.
+func (c *Copy[V]) LongestPrefix(key []byte) ([]byte, *Item[V], bool) {
+	return c.longest(key, func(*Item[V]) bool {
+		return true
+	})
+}
+
+// LongestPrefixAt returns the key and value of the longest key in the
+// tree which is a prefix of the specified key, skipping any key which
+// has no value visible at the specified version. If no such key exists,
+// then ok is false.
+func (c *Copy[V]) LongestPrefixAt(ver uint64, key []byte) ([]byte, *Item[V], bool) {
+	return c.longest(key, func(i *Item[V]) bool {
+		return i.live(ver)
+	})
 }
 
 // Cut is used to delete a given key, returning the previous value.
-func (c *Copy) Cut(key []byte) []byte {
+func (c *Copy[V]) Cut(key []byte) V {
+	c.log.add(opCut, 0, key, nil)
 	root, leaf, old := c.del(nil, c.root, key)
 	if root != nil {
 		c.root = root
 	}
 	if leaf != nil {
 		c.size--
+		c.remove(opCut, 0, key, nil)
+		c.emit(Change[V]{Op: OpCut, Key: key, Old: old})
 	}
 	return old
 }
 
-// Del is used to delete a given key, returning the previous value.
-func (c *Copy) Del(ver uint64, key []byte) []byte {
-	if val := c.root.get(key); val != nil {
-		return val.Del(ver)
+// Del is used to delete a given key at a specific version, by writing
+// a tombstone at that version, returning the previous value. Versions
+// prior to the tombstone remain visible, and the key is not removed.
+func (c *Copy[V]) Del(ver uint64, key []byte) (old V) {
+	c.log.add(opDel, ver, key, nil)
+	c.clock = max(c.clock, ver)
+	var mod bool
+	root := c.upd(c.root, key, func(i *Item[V]) *Item[V] {
+		n, o := i.del(ver)
+		mod, old = n != i, o
+		return n
+	})
+	if root != nil && mod {
+		c.root = root
+		c.emit(Change[V]{Op: OpDel, Key: key, Ver: ver, Old: old})
 	}
-	return nil
+	return
 }
 
 // Put is used to insert a specific key, returning the previous value.
-func (c *Copy) Put(ver uint64, key, val []byte) []byte {
-	root, leaf, old := c.put(nil, c.root, ver, key, key, val)
+func (c *Copy[V]) Put(ver uint64, key []byte, val V) (old V) {
+	c.log.put(ver, key, val)
+	c.clock = max(c.clock, ver)
+	root, leaf := c.put(nil, c.root, key, key, func(i *Item[V]) *Item[V] {
+		old = i.Get(ver)
+		return i.put(ver, val)
+	})
 	if root != nil {
 		c.root = root
 	}
 	if leaf == nil {
 		c.size++
 	}
+	c.emit(Change[V]{Op: OpPut, Key: key, Ver: ver, Old: old, New: val})
 	return old
 }
 
+// tomb writes a tombstone at a specific version, even if no value is
+// visible at that version, creating the key if it does not exist, so
+// that a tombstone is imported exactly as it was exported.
+func (c *Copy[V]) tomb(ver uint64, key []byte) {
+	c.log.add(opTomb, ver, key, nil)
+	c.clock = max(c.clock, ver)
+	var old V
+	root, leaf := c.put(nil, c.root, key, key, func(i *Item[V]) *Item[V] {
+		n, o := i.tomb(ver)
+		old = o
+		return n
+	})
+	if root != nil {
+		c.root = root
+	}
+	if leaf == nil {
+		c.size++
+	}
+	c.emit(Change[V]{Op: OpDel, Key: key, Ver: ver, Old: old})
+}
+
+// CutPrefix is used to delete every key which begins with the given
+// prefix, by detaching the whole subtree containing those keys with a
+// single path copy. It returns the number of keys which were removed.
+func (c *Copy[V]) CutPrefix(prefix []byte) int {
+	c.log.add(opCutRange, 0, prefix, after(prefix))
+	if len(prefix) == 0 {
+		num := c.size
+		c.cuts(c.root)
+		c.root, c.size = &Node[V]{}, 0
+		if num > 0 {
+			c.remove(opCutRange, 0, prefix, nil)
+		}
+		return num
+	}
+	root, num := c.cutPrefix(c.root, prefix)
+	if root != nil {
+		c.root = root
+	}
+	if num > 0 {
+		c.remove(opCutRange, 0, prefix, after(prefix))
+	}
+	c.size -= num
+	return num
+}
+
+// CutRange is used to delete every key which is greater than or equal
+// to the start key, and less than the end key, where a nil end key is
+// unbounded. Any subtree which lies wholly within the range is removed
+// without being visited. It returns the number of keys which were removed.
+func (c *Copy[V]) CutRange(start, end []byte) int {
+	c.log.add(opCutRange, 0, start, end)
+	var num int
+	c.root = c.cutRange(c.root, nil, start, end, &num)
+	if num > 0 {
+		c.remove(opCutRange, 0, start, end)
+	}
+	c.size -= num
+	return num
+}
+
+// DelPrefix is used to delete every key which begins with the given
+// prefix at a specific version, by writing a tombstone for each key
+// with a value visible at that version. It returns the number of keys
+// which were deleted.
+func (c *Copy[V]) DelPrefix(ver uint64, prefix []byte) int {
+	return c.DelRange(ver, prefix, after(prefix))
+}
+
+// DelRange is used to delete every key which is greater than or equal
+// to the start key, and less than the end key, at a specific version,
+// where a nil end key is unbounded. A tombstone is written for each key
+// with a value visible at that version, and each node in the range is
+// copied only once. It returns the number of keys which were deleted.
+func (c *Copy[V]) DelRange(ver uint64, start, end []byte) int {
+	c.log.add(opDelRange, ver, start, end)
+	c.clock = max(c.clock, ver)
+	var num int
+	c.root = c.delRange(c.root, nil, start, end, ver, &num)
+	return num
+}
+
+// Compact is used to remove old versions from every key in the tree.
+// For each key, the newest version at or below the specified version
+// is retained, along with all subsequent versions. Any key which is
+// left with only a tombstone is removed from the tree. It returns the
+// number of versions and the number of keys which were removed.
+func (c *Copy[V]) Compact(ver uint64) (vers, keys int) {
+	c.log.add(opCompact, ver, nil, nil)
+	c.root = c.compact(c.root, ver, &vers, &keys)
+	if vers > 0 || keys > 0 {
+		c.gone = c.gone.trim(ver)
+		c.remove(opCompact, ver, nil, nil)
+	}
+	c.size -= keys
+	return
+}
+
+// Forget discards the record of every Cut, CutPrefix, CutRange and
+// Compact which would not be exported by Tree.ExportSince for the
+// specified version. The records are otherwise kept until a Compact
+// at or after that version, so Forget should be called once every
+// follower has imported the specified version, to bound the memory
+// which they use.
+func (c *Copy[V]) Forget(ver uint64) {
+	c.gone = c.gone.trim(ver)
+}
+
+// CountPrefix returns the number of keys in the tree which begin with
+// the specified prefix, including any keys whose latest version is a
+// tombstone. It runs in time proportional to the depth of the tree.
+func (c *Copy[V]) CountPrefix(prefix []byte) int {
+	if n := c.root.sub(prefix); n != nil {
+		return n.size
+	}
+	return 0
+}
+
+// Rank returns the number of keys in the tree which are less than the
+// specified key, which is the index of the key if it exists in the tree.
+// It runs in time proportional to the depth of the tree.
+func (c *Copy[V]) Rank(key []byte) (r int) {
+
+	n := c.root
+
+	s := key
+
+	for len(s) > 0 {
+
+		// A leaf here is a prefix of the key
+		if n.isLeaf() {
+			r++
+		}
+
+		// Count the edges before the key
+		var e *Node[V]
+		for _, e = range n.edges {
+			if e.prefix[0] >= s[0] {
+				break
+			}
+			r += e.size
+		}
+
+		// Check the edge matching the key
+		if e == nil || e.prefix[0] != s[0] {
+			return
+		}
+
+		// Consume the search prefix
+		if bytes.HasPrefix(s, e.prefix) {
+			s, n = s[len(e.prefix):], e
+			continue
+		}
+
+		// The whole subtree precedes the key
+		if bytes.Compare(e.prefix, s) < 0 {
+			r += e.size
+		}
+
+		return
+
+	}
+
+	return
+
+}
+
+// Select returns the key and value at the specified index in the tree,
+// in ascending key order. If the index is out of range, then a nil key
+// and value are returned. It runs in time proportional to the depth of
+// the tree.
+func (c *Copy[V]) Select(i int) ([]byte, *Item[V]) {
+	return c.Cursor().SeekIndex(i)
+}
+
 // ---------------------------------------------------------------------------
 
 func prefix(a, b []byte) (i int) {
@@ -104,18 +355,143 @@ func concat(a, b []byte) (c []byte) {
 	return
 }
 
-func (c *Copy) del(p, n *Node, s []byte) (*Node, *leaf, []byte) {
+func (c *Copy[V]) longest(k []byte, f func(*Item[V]) bool) ([]byte, *Item[V], bool) {
+
+	var l *leaf[V]
+
+	n := c.root
+
+	s := k
+
+	for {
+
+		// Keep the deepest matching leaf
+		if n.isLeaf() && f(n.leaf.val) {
+			l = n.leaf
+		}
+
+		// Check for key exhaution
+		if len(s) == 0 {
+			break
+		}
+
+		// Look for an edge
+		if _, n = n.getSub(s[0]); n == nil {
+			break
+		}
+
+		// Consume the search prefix
+		if bytes.HasPrefix(s, n.prefix) {
+			s = s[len(n.prefix):]
+		} else {
+			break
+		}
+
+	}
+
+	if l == nil {
+		return nil, nil, false
+	}
+
+	return l.key, l.val, true
+
+}
+
+// after returns the smallest key which is greater than every key with
+// the given prefix, or nil if there is no such key.
+func after(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			k := make([]byte, i+1)
+			copy(k, prefix)
+			k[i]++
+			return k
+		}
+	}
+	return nil
+}
+
+// within returns whether the key is within the range.
+func within(key, beg, end []byte) bool {
+	return bytes.Compare(key, beg) >= 0 && (end == nil || bytes.Compare(key, end) < 0)
+}
+
+// covers returns whether every key with the prefix is within the range.
+func covers(p, beg, end []byte) bool {
+	if bytes.Compare(p, beg) < 0 {
+		return false
+	}
+	if end == nil {
+		return true
+	}
+	return bytes.Compare(p, end) < 0 && !bytes.HasPrefix(end, p)
+}
+
+// overlaps returns whether any key with the prefix may be within the range.
+func overlaps(p, beg, end []byte) bool {
+	if bytes.Compare(p, beg) < 0 && !bytes.HasPrefix(beg, p) {
+		return false
+	}
+	return end == nil || bytes.Compare(p, end) < 0
+}
+
+// emit records a change to the changelog of the copy, if the copy is
+// tracking changes for any subscribers.
+func (c *Copy[V]) emit(ch Change[V]) {
+	if c.track {
+		c.feed = append(c.feed, ch)
+	}
+}
+
+// remove records a removal made by the copy, so that it can be
+// exported in a delta. The keys are copied, as they are retained.
+func (c *Copy[V]) remove(kind byte, ver uint64, key, end []byte) {
+	c.gone = &removal{kind: kind, at: c.clock + 1, ver: ver, key: bytes.Clone(key), end: bytes.Clone(end), next: c.gone}
+}
+
+// cuts records the removal of every key in the subtree of the node.
+func (c *Copy[V]) cuts(n *Node[V]) {
+	if c.track {
+		walk(n, func(key []byte, val *Item[V]) bool {
+			c.emit(Change[V]{Op: OpCut, Key: key, Old: val.Max()})
+			return false
+		}, false)
+	}
+}
+
+// seal moves the copy on to a new generation, so that any nodes which
+// it has created are shared, and are copied before being modified.
+func (c *Copy[V]) seal() {
+	c.gen = atomic.AddUint64(&gens, 1)
+}
+
+// own returns a node which can be modified in place by the copy. Any
+// node created by the copy in the current generation is only reachable
+// from the copy, and so is returned as is, otherwise it is duplicated.
+func (c *Copy[V]) own(n *Node[V]) *Node[V] {
+	if n.gen == c.gen {
+		return n
+	}
+	d := n.dup()
+	d.gen = c.gen
+	return d
+}
+
+func (c *Copy[V]) del(p, n *Node[V], s []byte) (_ *Node[V], _ *leaf[V], old V) {
 
 	if len(s) == 0 {
 
 		if !n.isLeaf() {
-			return nil, nil, nil
+			return nil, nil, old
 		}
 
-		d := n.dup()
+		l := n.leaf
+
+		d := c.own(n)
 
 		// Remove the leaf node
 		d.leaf = nil
+		d.size--
 
 		// Check if the node should be merged
 		if n != c.root && len(d.edges) == 1 {
@@ -123,7 +499,7 @@ func (c *Copy) del(p, n *Node, s []byte) (*Node, *leaf, []byte) {
 		}
 
 		// Return the found node and leaf node
-		return d, n.leaf, n.leaf.val.Max()
+		return d, l, l.val.Max()
 
 	}
 
@@ -131,7 +507,7 @@ func (c *Copy) del(p, n *Node, s []byte) (*Node, *leaf, []byte) {
 	l := s[0]
 	i, e := n.getSub(l)
 	if e == nil || !bytes.HasPrefix(s, e.prefix) {
-		return nil, nil, nil
+		return nil, nil, old
 	}
 
 	// Consume the search prefix
@@ -139,45 +515,185 @@ func (c *Copy) del(p, n *Node, s []byte) (*Node, *leaf, []byte) {
 
 	node, leaf, old := c.del(n, e, s)
 	if node == nil {
-		return nil, nil, nil
+		return nil, nil, old
 	}
 
 	// Copy this node
-	d := n.dup()
+	d := c.own(n)
 
 	// Delete the edge if the node has no edges
 	if node.leaf == nil && len(node.edges) == 0 {
 		d.delSub(l)
+		d.size--
 		if n != c.root && len(d.edges) == 1 && !d.isLeaf() {
 			d.mergeChild()
 		}
 	} else {
 		d.edges[i] = node
+		d.size--
 	}
 
 	return d, leaf, old
 
 }
 
-func (c *Copy) put(p, n *Node, t uint64, s, k, v []byte) (*Node, *leaf, []byte) {
+func (c *Copy[V]) cutPrefix(n *Node[V], s []byte) (*Node[V], int) {
+
+	// Look for an edge
+	i, e := n.getSub(s[0])
+	if e == nil {
+		return nil, 0
+	}
+
+	d := c.own(n)
+
+	var num int
+
+	switch {
+	case bytes.HasPrefix(e.prefix, s):
+		// The whole subtree has the prefix
+		num = e.size
+		c.cuts(e)
+		d.delSub(s[0])
+	case bytes.HasPrefix(s, e.prefix):
+		// Consume the search prefix
+		node, n := c.cutPrefix(e, s[len(e.prefix):])
+		if node == nil {
+			return nil, 0
+		}
+		num = n
+		d.edges[i] = node
+	default:
+		return nil, 0
+	}
+
+	d.size -= num
+
+	// Check if the node should be merged
+	if n != c.root && !d.isLeaf() && len(d.edges) == 1 {
+		d.mergeChild()
+	}
+
+	return d, num
+
+}
+
+func (c *Copy[V]) cutRange(n *Node[V], k, beg, end []byte, num *int) *Node[V] {
+
+	d, mod := n, false
+
+	// Remove the leaf if within the range
+	if n.isLeaf() && within(n.leaf.key, beg, end) {
+		c.emit(Change[V]{Op: OpCut, Key: n.leaf.key, Old: n.leaf.val.Max()})
+		d, mod = c.own(n), true
+		d.leaf = nil
+		*num++
+	}
+
+	// Remove or recurse into the child nodes
+	for i, e := range n.edges {
+		p := concat(k, e.prefix)
+		node, cut := e, *num
+		switch {
+		case covers(p, beg, end):
+			node = nil
+			*num += e.size
+			c.cuts(e)
+		case overlaps(p, beg, end):
+			node = c.cutRange(e, p, beg, end, num)
+		}
+		if node == e && cut == *num {
+			continue
+		}
+		if !mod {
+			d, mod = c.own(n), true
+		}
+		d.edges[i] = node
+	}
+
+	if !mod {
+		return n
+	}
+
+	// Remove any deleted edges
+	edges := d.edges[:0]
+	for _, e := range d.edges {
+		if e != nil {
+			edges = append(edges, e)
+		}
+	}
+	d.edges = edges
+
+	d.count()
+
+	if n != c.root {
+		// Delete the node if it is empty
+		if !d.isLeaf() && len(d.edges) == 0 {
+			return nil
+		}
+		// Check if the node should be merged
+		if !d.isLeaf() && len(d.edges) == 1 {
+			d.mergeChild()
+		}
+	}
+
+	return d
+
+}
+
+func (c *Copy[V]) delRange(n *Node[V], k, beg, end []byte, ver uint64, num *int) *Node[V] {
+
+	d, mod := n, false
+
+	// Write a tombstone if within the range
+	if n.isLeaf() && within(n.leaf.key, beg, end) {
+		if val, old := n.leaf.val.del(ver); val != n.leaf.val {
+			c.emit(Change[V]{Op: OpDel, Key: n.leaf.key, Ver: ver, Old: old})
+			d, mod = c.own(n), true
+			d.leaf.val = val
+			*num++
+		}
+	}
+
+	// Recurse into the child nodes
+	for i, e := range n.edges {
+		p := concat(k, e.prefix)
+		if !overlaps(p, beg, end) {
+			continue
+		}
+		node := c.delRange(e, p, beg, end, ver, num)
+		if node == e {
+			continue
+		}
+		if !mod {
+			d, mod = c.own(n), true
+		}
+		d.edges[i] = node
+	}
+
+	return d
+
+}
+
+func (c *Copy[V]) put(p, n *Node[V], s, k []byte, f func(*Item[V]) *Item[V]) (*Node[V], *leaf[V]) {
 
 	if len(s) == 0 {
 
-		d := n.dup()
+		d := c.own(n)
 
 		// Create the leaf if necessary
-		if !n.isLeaf() {
-			d.leaf = &leaf{key: k, val: newItem()}
+		if d.leaf == nil {
+			d.leaf = &leaf[V]{key: k, val: f(newItem[V]())}
+			d.size++
+			return d, nil
 		}
 
-		// Get the old value
-		o := n.leaf.val.Get(t)
-
 		// Update the leaf value
-		d.leaf.val.Put(t, v)
+		l := d.leaf
+		d.leaf.val = f(l.val)
 
 		// Return the new node and leaf node
-		return d, n.leaf, o
+		return d, l
 
 	}
 
@@ -186,17 +702,19 @@ func (c *Copy) put(p, n *Node, t uint64, s, k, v []byte) (*Node, *leaf, []byte)
 
 	// No edge, create one
 	if e == nil {
-		e := &Node{
-			leaf: &leaf{
+		e := &Node[V]{
+			leaf: &leaf[V]{
 				key: k,
-				val: newItem(),
+				val: f(newItem[V]()),
 			},
 			prefix: s,
+			size:   1,
+			gen:    c.gen,
 		}
-		e.leaf.val.Put(t, v)
-		d := n.dup()
+		d := c.own(n)
 		d.addSub(e)
-		return d, nil, nil
+		d.size++
+		return d, nil
 	}
 
 	// Determine longest prefix of the search key on match
@@ -204,47 +722,155 @@ func (c *Copy) put(p, n *Node, t uint64, s, k, v []byte) (*Node, *leaf, []byte)
 
 	if cl == len(e.prefix) {
 		s = s[cl:]
-		node, leaf, old := c.put(n, e, t, s, k, v)
+		node, leaf := c.put(n, e, s, k, f)
 		if node != nil {
-			nc := n.dup()
+			nc := c.own(n)
 			nc.edges[i] = node
-			return nc, leaf, old
+			if leaf == nil {
+				nc.size++
+			}
+			return nc, leaf
 		}
-		return nil, leaf, old
+		return nil, leaf
 	}
 
 	// Split the node
-	nc := n.dup()
-	splitNode := &Node{
+	nc := c.own(n)
+	nc.size++
+	splitNode := &Node[V]{
 		prefix: s[:cl],
+		size:   e.size + 1,
+		gen:    c.gen,
 	}
 	nc.repSub(splitNode)
 
 	// Restore the existing child node
-	modChild := e.dup()
+	modChild := c.own(e)
 	splitNode.addSub(modChild)
 	modChild.prefix = modChild.prefix[cl:]
 
 	// Create a new leaf node
-	leaf := &leaf{
+	leaf := &leaf[V]{
 		key: k,
-		val: newItem(),
+		val: f(newItem[V]()),
 	}
-	leaf.val.Put(t, v)
 
 	// If the new key is a subset, add to to this node
 	s = s[cl:]
 	if len(s) == 0 {
 		splitNode.leaf = leaf
-		return nc, nil, nil
+		return nc, nil
 	}
 
 	// Create a new edge for the node
-	splitNode.addSub(&Node{
+	splitNode.addSub(&Node[V]{
 		leaf:   leaf,
 		prefix: s,
+		size:   1,
+		gen:    c.gen,
 	})
 
-	return nc, nil, nil
+	return nc, nil
+
+}
+
+func (c *Copy[V]) upd(n *Node[V], s []byte, f func(*Item[V]) *Item[V]) *Node[V] {
+
+	if len(s) == 0 {
+
+		if !n.isLeaf() {
+			return nil
+		}
+
+		d := c.own(n)
+
+		// Replace the leaf value
+		d.leaf.val = f(n.leaf.val)
+
+		return d
+
+	}
+
+	// Look for an edge
+	i, e := n.getSub(s[0])
+	if e == nil || !bytes.HasPrefix(s, e.prefix) {
+		return nil
+	}
+
+	// Consume the search prefix
+	s = s[len(e.prefix):]
+
+	node := c.upd(e, s, f)
+	if node == nil {
+		return nil
+	}
+
+	// Copy this node
+	d := c.own(n)
+	d.edges[i] = node
+
+	return d
+
+}
+
+func (c *Copy[V]) compact(n *Node[V], t uint64, vers, keys *int) *Node[V] {
+
+	d, mod := n, false
+
+	// Compact the leaf versions
+	if n.isLeaf() {
+		val, num := n.leaf.val.trim(t)
+		*vers += num
+		if l := val.list; l == nil || l.size == 1 && l.dead {
+			d, mod = c.own(n), true
+			d.leaf = nil
+			*vers += l.len()
+			*keys++
+		} else if val != n.leaf.val {
+			d, mod = c.own(n), true
+			d.leaf.val = val
+		}
+	}
+
+	// Compact the child nodes
+	for i, e := range n.edges {
+		cut := *keys
+		node := c.compact(e, t, vers, keys)
+		if node == e && cut == *keys {
+			continue
+		}
+		if !mod {
+			d, mod = c.own(n), true
+		}
+		d.edges[i] = node
+	}
+
+	if !mod {
+		return n
+	}
+
+	// Remove any deleted edges
+	edges := d.edges[:0]
+	for _, e := range d.edges {
+		if e != nil {
+			edges = append(edges, e)
+		}
+	}
+	d.edges = edges
+
+	d.count()
+
+	if n != c.root {
+		// Delete the node if it is empty
+		if !d.isLeaf() && len(d.edges) == 0 {
+			return nil
+		}
+		// Check if the node should be merged
+		if !d.isLeaf() && len(d.edges) == 1 {
+			d.mergeChild()
+		}
+	}
+
+	return d
 
 }
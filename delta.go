@@ -0,0 +1,314 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+	"slices"
+)
+
+// A delta, as written by Tree.ExportSince, uses the same encoding as
+// a snapshot for each key and version, and is laid out as follows:
+//
+//	magic       4 bytes   the characters "VTRD"
+//	format      1 byte    the snapshot format version, currently 1
+//	since       varint    the version which the delta was exported since
+//	for each removal, in the order in which it was made:
+//	  more      1 byte    the value 2
+//	  kind      1 byte    2 for Cut, 3 for Compact, and 4 for CutRange
+//	  at        varint    the version at which the removal is ordered
+//	  ver       varint    the version, for Compact only
+//	  klen      varint    the length of the key, or the start of the
+//	                      range, except for Compact
+//	  key       klen      the key, or the start of the range
+//	  elen      varint    the length of the end of the range, plus
+//	                      one, or 0 for nil, for CutRange only
+//	  end       elen-1    the end of the range
+//	for each changed key, in ascending order of key:
+//	  more      1 byte    the value 1
+//	  key       ...       the key and its changed versions, as above
+//	more        1 byte    the value 0
+//	checksum    4 bytes   big-endian CRC-32C of all preceding bytes
+
+var deltaMagic = []byte("VTRD")
+
+// removal records a Cut, CutRange or Compact which removed something
+// from the tree, so that it can be exported in a delta. Removals are
+// kept in a persistent list, newest first. As Cut and CutRange have
+// no version, each removal is ordered at one more than the greatest
+// version written before it, so that it follows every version which
+// it could have removed.
+type removal struct {
+	kind byte
+	at   uint64
+	ver  uint64
+	key  []byte
+	end  []byte
+	next *removal
+}
+
+// trim returns the list without any removals ordered at or before the
+// specified version, copying only the removals which are retained.
+func (r *removal) trim(ver uint64) *removal {
+	var keep []*removal
+	for e := r; e != nil; e = e.next {
+		if e.at <= ver {
+			break
+		}
+		keep = append(keep, e)
+	}
+	if len(keep) == 0 {
+		return nil
+	}
+	if keep[len(keep)-1].next == nil {
+		return r
+	}
+	var out *removal
+	for i := len(keep) - 1; i >= 0; i-- {
+		d := *keep[i]
+		d.next, out = out, &d
+	}
+	return out
+}
+
+// ExportSince writes a delta of the tree to the writer, containing
+// only those versions, including tombstones, which are newer than the
+// specified version, along with any Cut, CutPrefix, CutRange and
+// Compact which removed something after that version was written. A
+// follower which has applied every version up to and including the
+// specified version can catch up by importing the delta with
+// Copy.ImportDelta. Removals are only held in memory, until they are
+// discarded by Copy.Forget or Copy.Compact, so a tree read from a
+// snapshot exports only the removals made since it was read.
+// It returns the number of bytes written, and returns ErrValueType
+// if the values of the tree are not []byte.
+func (t *Tree[V]) ExportSince(ver uint64, w io.Writer) (int64, error) {
+
+	e := newEncoder(w)
+
+	e.header(deltaMagic)
+	e.uvarint(ver)
+
+	var gone []*removal
+	for r := t.gone; r != nil && r.at > ver; r = r.next {
+		gone = append(gone, r)
+	}
+
+	for i := len(gone) - 1; i >= 0; i-- {
+		e.write([]byte{2})
+		writeRemoval(e, gone[i])
+	}
+
+	t.root.Walk(nil, func(key []byte, val *Item[V]) bool {
+		if list := val.list.since(ver); list != nil {
+			e.write([]byte{1})
+			writeItem(e, key, list)
+		}
+		return e.err != nil
+	})
+
+	e.write([]byte{0})
+
+	return e.close()
+
+}
+
+// ImportDelta applies a delta read from the reader, as written by
+// Tree.ExportSince. Each value and tombstone is written to the copy at
+// its original version, replacing any existing entry at that version,
+// and is recorded as a single operation. The versions and removals are
+// applied in order of version, with each removal applied after every
+// version which was written before it was made. The copy is only
+// modified if the whole delta is read successfully. It returns the
+// number of bytes read.
+func (c *Copy[V]) ImportDelta(r io.Reader) (int64, error) {
+
+	d := newDecoder(r)
+
+	if err := d.header(deltaMagic); err != nil {
+		return d.n, err
+	}
+
+	if _, err := d.uvarint(); err != nil {
+		return d.n, err
+	}
+
+	var gone []*removal
+	var keys [][]byte
+	var vals []*Item[V]
+
+	for {
+
+		more, err := d.ReadByte()
+		if err != nil {
+			return d.n, eof(err)
+		}
+
+		if more == 0 {
+			break
+		}
+
+		if more == 2 && len(keys) == 0 {
+			r, err := readRemoval(d)
+			if err != nil {
+				return d.n, err
+			}
+			if len(gone) > 0 && r.at < gone[len(gone)-1].at {
+				return d.n, ErrSnapshotFormat
+			}
+			gone = append(gone, r)
+			continue
+		}
+
+		if more != 1 {
+			return d.n, ErrSnapshotFormat
+		}
+
+		key, val, err := readItem[V](d)
+		if err != nil {
+			return d.n, err
+		}
+
+		if len(keys) > 0 && bytes.Compare(key, keys[len(keys)-1]) <= 0 {
+			return d.n, ErrSnapshotFormat
+		}
+
+		keys, vals = append(keys, key), append(vals, val)
+
+	}
+
+	if err := d.close(); err != nil {
+		return d.n, err
+	}
+
+	type entry struct {
+		key []byte
+		*elem[V]
+	}
+
+	var all []entry
+	for i, key := range keys {
+		vals[i].list.walk(func(e *elem[V]) bool {
+			all = append(all, entry{key, e})
+			return false
+		})
+	}
+
+	slices.SortStableFunc(all, func(a, b entry) int {
+		return cmp.Compare(a.ver, b.ver)
+	})
+
+	for _, e := range all {
+		for len(gone) > 0 && gone[0].at <= e.ver {
+			redo(c, gone[0])
+			gone = gone[1:]
+		}
+		if e.dead {
+			c.tomb(e.ver, e.key)
+		} else {
+			c.Put(e.ver, e.key, e.val)
+		}
+	}
+
+	for _, r := range gone {
+		redo(c, r)
+	}
+
+	return d.n, nil
+
+}
+
+// redo applies a removal which was read from a delta to the copy.
+func redo[V any](c *Copy[V], r *removal) {
+	switch r.kind {
+	case opCut:
+		c.Cut(r.key)
+	case opCutRange:
+		c.CutRange(r.key, r.end)
+	case opCompact:
+		c.Compact(r.ver)
+	}
+}
+
+func writeRemoval(e *encoder, r *removal) {
+	e.write([]byte{r.kind})
+	e.uvarint(r.at)
+	if r.kind == opCompact {
+		e.uvarint(r.ver)
+		return
+	}
+	e.uvarint(uint64(len(r.key)))
+	e.write(r.key)
+	if r.kind == opCutRange {
+		if r.end == nil {
+			e.uvarint(0)
+		} else {
+			e.uvarint(uint64(len(r.end)) + 1)
+			e.write(r.end)
+		}
+	}
+}
+
+func readRemoval(d *decoder) (*removal, error) {
+
+	r := &removal{}
+
+	kind, err := d.ReadByte()
+	if err != nil {
+		return nil, eof(err)
+	}
+
+	switch r.kind = kind; r.kind {
+	case opCut, opCutRange, opCompact:
+	default:
+		return nil, ErrSnapshotFormat
+	}
+
+	if r.at, err = d.uvarint(); err != nil {
+		return nil, err
+	}
+
+	if r.kind == opCompact {
+		if r.ver, err = d.uvarint(); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.key, err = d.read(n); err != nil {
+		return nil, err
+	}
+
+	if r.kind == opCutRange {
+		if n, err = d.uvarint(); err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			if r.end, err = d.read(n - 1); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r, nil
+
+}
@@ -0,0 +1,260 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDelta(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(7))
+
+	change := func(c *Copy[[]byte], ver uint64) {
+		for i := 0; i < 100; i++ {
+			key := []byte(fmt.Sprintf("/key/%d", rng.Intn(60)))
+			switch rng.Intn(4) {
+			case 0:
+				c.Del(ver, key)
+			case 1:
+				c.Put(ver, key, nil)
+			default:
+				c.Put(ver, key, []byte(fmt.Sprint(rng.Int())))
+			}
+		}
+	}
+
+	c := New[[]byte]().Copy()
+	for ver := uint64(1); ver <= 3; ver++ {
+		change(c, ver)
+	}
+	base := c.Tree()
+	for ver := uint64(4); ver <= 6; ver++ {
+		change(c, ver)
+	}
+	tree := c.Tree()
+
+	var buf bytes.Buffer
+
+	n, err := tree.ExportSince(3, &buf)
+
+	Convey("Can export the changes since a version", t, func() {
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, buf.Len())
+		var all bytes.Buffer
+		_, err := tree.ExportSince(0, &all)
+		So(err, ShouldBeNil)
+		So(buf.Len(), ShouldBeLessThan, all.Len())
+	})
+
+	Convey("Can import the changes since a version", t, func() {
+		c := base.Copy()
+		m, err := c.ImportDelta(bytes.NewReader(buf.Bytes()))
+		So(err, ShouldBeNil)
+		So(m, ShouldEqual, n)
+		So(c.Size(), ShouldEqual, tree.Size())
+		So(dump(c.Tree()), ShouldResemble, dump(tree))
+		So(dump(base), ShouldNotResemble, dump(tree))
+	})
+
+	Convey("Can import all changes into an empty tree", t, func() {
+		var all bytes.Buffer
+		_, err := tree.ExportSince(0, &all)
+		So(err, ShouldBeNil)
+		c := New[[]byte]().Copy()
+		_, err = c.ImportDelta(&all)
+		So(err, ShouldBeNil)
+		So(dump(c.Tree()), ShouldResemble, dump(tree))
+	})
+
+	Convey("Can export an empty delta", t, func() {
+		var buf bytes.Buffer
+		_, err := tree.ExportSince(6, &buf)
+		So(err, ShouldBeNil)
+		So(buf.Len(), ShouldEqual, 4+1+1+1+4)
+		c := base.Copy()
+		_, err = c.ImportDelta(&buf)
+		So(err, ShouldBeNil)
+		So(dump(c.Tree()), ShouldResemble, dump(base))
+	})
+
+	Convey("Can export and import removed keys", t, func() {
+		c := New[[]byte]().Copy()
+		for _, k := range []string{"", "/a", "/b/1", "/b/2", "/c", "/d"} {
+			c.Put(1, []byte(k), []byte(k))
+		}
+		c.Put(2, []byte("/d"), []byte("D"))
+		base := c.Tree()
+		c.Cut([]byte(""))
+		c.Cut([]byte("/none"))
+		c.CutPrefix([]byte("/b/"))
+		c.Put(3, []byte("/b/3"), []byte("B"))
+		c.CutRange([]byte("/c"), []byte("/d"))
+		c.Compact(2)
+		tree := c.Tree()
+		var buf bytes.Buffer
+		_, err := tree.ExportSince(1, &buf)
+		So(err, ShouldBeNil)
+		f := base.Copy()
+		_, err = f.ImportDelta(&buf)
+		So(err, ShouldBeNil)
+		So(f.Size(), ShouldEqual, tree.Size())
+		So(dump(f.Tree()), ShouldResemble, dump(tree))
+		buf.Reset()
+		_, err = tree.ExportSince(4, &buf)
+		So(err, ShouldBeNil)
+		So(buf.Len(), ShouldEqual, 4+1+1+1+4)
+	})
+
+	Convey("Can import removals in the order in which they were made", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/a"), []byte("A1"))
+		c.Put(1, []byte("/b"), []byte("B1"))
+		base := c.Tree()
+		c.Put(2, []byte("/a"), []byte("A2"))
+		c.Compact(2)
+		c.Put(3, []byte("/a"), []byte("A3"))
+		c.Put(3, []byte("/b"), []byte("B3"))
+		c.Cut([]byte("/b"))
+		c.Put(4, []byte("/b"), []byte("B4"))
+		tree := c.Tree()
+		So(dump(tree), ShouldResemble, []string{
+			`/a@2="A2"/false/false`, `/a@3="A3"/false/false`, `/b@4="B4"/false/false`,
+		})
+		var buf bytes.Buffer
+		_, err := tree.ExportSince(1, &buf)
+		So(err, ShouldBeNil)
+		f := base.Copy()
+		_, err = f.ImportDelta(&buf)
+		So(err, ShouldBeNil)
+		So(f.Size(), ShouldEqual, tree.Size())
+		So(dump(f.Tree()), ShouldResemble, dump(tree))
+	})
+
+	Convey("Can forget removed keys once they are imported", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/a"), []byte("A"))
+		c.Put(1, []byte("/b"), []byte("B"))
+		base := c.Tree()
+		key := []byte("/a")
+		c.Cut(key)
+		copy(key, "/b")
+		c.Put(2, []byte("/c"), []byte("C"))
+		c.Cut([]byte("/c"))
+		var buf bytes.Buffer
+		_, err := c.Tree().ExportSince(1, &buf)
+		So(err, ShouldBeNil)
+		f := base.Copy()
+		_, err = f.ImportDelta(&buf)
+		So(err, ShouldBeNil)
+		So(dump(f.Tree()), ShouldResemble, []string{`/b@1="B"/false/false`})
+		c.Forget(2)
+		So(c.gone.at, ShouldEqual, 3)
+		So(c.gone.next, ShouldBeNil)
+		c.Forget(3)
+		So(c.gone, ShouldBeNil)
+		buf.Reset()
+		_, err = c.Tree().ExportSince(1, &buf)
+		So(err, ShouldBeNil)
+		So(buf.Len(), ShouldEqual, 4+1+1+1+4)
+	})
+
+	Convey("Can roll back removed keys before exporting", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/a"), []byte("A"))
+		sp := c.Savepoint()
+		c.Cut([]byte("/a"))
+		So(c.RollbackTo(sp), ShouldBeNil)
+		var buf bytes.Buffer
+		_, err := c.Tree().ExportSince(1, &buf)
+		So(err, ShouldBeNil)
+		So(buf.Len(), ShouldEqual, 4+1+1+1+4)
+	})
+
+	Convey("Can import a tombstone as a single deletion", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/a"), []byte("A"))
+		c.Put(2, []byte("/b"), []byte("B"))
+		c.Del(2, []byte("/b"))
+		var buf bytes.Buffer
+		_, err := c.Tree().ExportSince(0, &buf)
+		So(err, ShouldBeNil)
+		d, err := Open(t.TempDir())
+		So(err, ShouldBeNil)
+		defer d.Close()
+		sub := d.Subscribe(1)
+		defer sub.Close()
+		So(d.Update(func(c *Copy[[]byte]) error {
+			_, err := c.ImportDelta(&buf)
+			So(c.log.Len(), ShouldEqual, 2)
+			return err
+		}), ShouldBeNil)
+		cs := <-sub.C
+		So(cs.Changes, ShouldResemble, []Change[[]byte]{
+			{Op: OpPut, Key: []byte("/a"), Ver: 1, New: []byte("A")},
+			{Op: OpDel, Key: []byte("/b"), Ver: 2},
+		})
+		So(d.Close(), ShouldBeNil)
+		d, err = Open(d.path)
+		So(err, ShouldBeNil)
+		defer d.Close()
+		So(dump(d.Load()), ShouldResemble, dump(c.Tree()))
+	})
+
+	Convey("Can not import a snapshot as a delta", t, func() {
+		var snap bytes.Buffer
+		_, err := tree.WriteTo(&snap)
+		So(err, ShouldBeNil)
+		_, err = New[[]byte]().Copy().ImportDelta(&snap)
+		So(err, ShouldEqual, ErrSnapshotFormat)
+	})
+
+	Convey("Can not import a corrupted delta", t, func() {
+		bad := append([]byte{}, buf.Bytes()...)
+		bad[len(bad)-2] ^= 0x01
+		c := base.Copy()
+		_, err := c.ImportDelta(bytes.NewReader(bad))
+		So(err, ShouldEqual, ErrSnapshotChecksum)
+		So(dump(c.Tree()), ShouldResemble, dump(base))
+	})
+
+	Convey("Can not import a delta with corrupted lengths", t, func() {
+		head := appendUvarint(append([]byte("VTRD"), snapFormat), 3)
+		keys := appendUvarint(append(append([]byte{}, head...), 1), 1<<62)
+		vers := appendUvarint(appendUvarint(append(append([]byte{}, head...), 1), 1), '/')
+		vers = appendUvarint(vers, 1<<61)
+		more := append(append([]byte{}, head...), 7)
+		for _, bad := range []struct {
+			data []byte
+			err  error
+		}{
+			{keys, io.ErrUnexpectedEOF},
+			{vers, io.ErrUnexpectedEOF},
+			{more, ErrSnapshotFormat},
+		} {
+			c := base.Copy()
+			_, err := c.ImportDelta(bytes.NewReader(bad.data))
+			So(err, ShouldEqual, bad.err)
+			So(dump(c.Tree()), ShouldResemble, dump(base))
+		}
+	})
+
+}
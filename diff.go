@@ -0,0 +1,112 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bytes"
+)
+
+type step[V any] struct {
+	path []byte
+	node *Node[V]
+}
+
+// Diff is used to compare two trees, calling the specified function
+// for every key which differs between the two trees, in key order.
+// The function is populated with the key, and the items before and
+// after the change, either of which is nil if the key does not exist
+// in that tree. Any subtrees which are shared between the two trees
+// are skipped, so the cost of the comparison is proportional to the
+// number of changed nodes. The function returns a bool signifying if
+// the comparison should be terminated.
+func Diff[V any](a, b *Tree[V], fn func(key []byte, before, after *Item[V]) bool) {
+
+	x := []*step[V]{{path: a.root.prefix, node: a.root}}
+	y := []*step[V]{{path: b.root.prefix, node: b.root}}
+
+	for len(x) > 0 || len(y) > 0 {
+
+		var c int
+
+		switch {
+		case len(x) == 0:
+			c = 1
+		case len(y) == 0:
+			c = -1
+		default:
+			c = bytes.Compare(x[len(x)-1].path, y[len(y)-1].path)
+		}
+
+		switch {
+
+		case c < 0:
+
+			n := x[len(x)-1]
+			x = push(x[:len(x)-1], n)
+
+			if n.node.isLeaf() && fn(n.path, n.node.leaf.val, nil) {
+				return
+			}
+
+		case c > 0:
+
+			m := y[len(y)-1]
+			y = push(y[:len(y)-1], m)
+
+			if m.node.isLeaf() && fn(m.path, nil, m.node.leaf.val) {
+				return
+			}
+
+		default:
+
+			n, m := x[len(x)-1], y[len(y)-1]
+			x, y = x[:len(x)-1], y[:len(y)-1]
+
+			// Skip any shared subtree
+			if n.node == m.node {
+				continue
+			}
+
+			x, y = push(x, n), push(y, m)
+
+			var before, after *Item[V]
+
+			if n.node.isLeaf() {
+				before = n.node.leaf.val
+			}
+
+			if m.node.isLeaf() {
+				after = m.node.leaf.val
+			}
+
+			if before != after && fn(n.path, before, after) {
+				return
+			}
+
+		}
+
+	}
+
+}
+
+// push adds the child nodes of the specified step to the stack,
+// in reverse order, so that the nodes are popped in key order.
+func push[V any](s []*step[V], n *step[V]) []*step[V] {
+	for i := len(n.node.edges) - 1; i >= 0; i-- {
+		e := n.node.edges[i]
+		s = append(s, &step[V]{path: concat(n.path, e.prefix), node: e})
+	}
+	return s
+}
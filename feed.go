@@ -0,0 +1,127 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrOverflow is returned by a Subscription which was closed because
+// its buffer was full when a commit was published.
+var ErrOverflow = errors.New("vtree: subscription buffer overflowed")
+
+// ChangeOp represents the kind of operation which caused a change.
+type ChangeOp int
+
+const (
+	// OpPut is a value which was written at a version.
+	OpPut ChangeOp = iota
+	// OpDel is a tombstone which was written at a version.
+	OpDel
+	// OpCut is a key which was removed along with all of its versions.
+	OpCut
+)
+
+// Change describes a single change made to a key within a commit. For
+// a Put or a Del, Old is the value which was visible at the version
+// before the change. For a Cut, Ver is zero, and Old is the value of
+// the latest version of the key.
+type Change[V any] struct {
+	Op  ChangeOp
+	Key []byte
+	Ver uint64
+	Old V
+	New V
+}
+
+// Changeset is the ordered changelog of a single commit, along with
+// the tree which was published by the commit.
+type Changeset[V any] struct {
+	Tree    *Tree[V]
+	Changes []Change[V]
+}
+
+// Subscription receives a Changeset for each commit to a Store which
+// changes the tree, in the order in which the commits were published.
+// Changes which are rolled back, or discarded, are never delivered.
+// If the buffer of the subscription is full when a commit is published,
+// then the subscription is closed, and Err returns ErrOverflow, so that
+// the subscriber can resynchronise from the latest tree.
+type Subscription[V any] struct {
+	// C delivers a Changeset for each commit, and is closed when the
+	// subscription is closed.
+	C <-chan *Changeset[V]
+
+	lock  sync.Mutex
+	err   error
+	ch    chan *Changeset[V]
+	store *Store[V]
+}
+
+// Subscribe returns a new subscription to the commits published by the
+// store, buffering up to the specified number of commits.
+func (s *Store[V]) Subscribe(size int) *Subscription[V] {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ch := make(chan *Changeset[V], size)
+	sub := &Subscription[V]{C: ch, ch: ch, store: s}
+	if s.subs == nil {
+		s.subs = make(map[*Subscription[V]]struct{})
+	}
+	s.subs[sub] = struct{}{}
+	return sub
+}
+
+// Err returns ErrOverflow if the subscription was closed because its
+// buffer overflowed, and nil otherwise.
+func (s *Subscription[V]) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// Close stops the subscription, and closes its channel.
+func (s *Subscription[V]) Close() {
+	s.store.lock.Lock()
+	defer s.store.lock.Unlock()
+	if _, ok := s.store.subs[s]; ok {
+		s.stop(nil)
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+// stop removes the subscription from the store, and must be called
+// while the store is locked.
+func (s *Subscription[V]) stop(err error) {
+	s.lock.Lock()
+	s.err = err
+	s.lock.Unlock()
+	delete(s.store.subs, s)
+	close(s.ch)
+}
+
+// publish delivers the changeset to every subscription without
+// blocking, and must be called while the store is locked.
+func (s *Store[V]) publish(cs *Changeset[V]) {
+	for sub := range s.subs {
+		select {
+		case sub.ch <- cs:
+		default:
+			sub.stop(ErrOverflow)
+		}
+	}
+}
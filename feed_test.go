@@ -0,0 +1,172 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFeed(t *testing.T) {
+
+	Convey("Can receive the changes of each commit", t, func() {
+		st := NewStore[[]byte](nil)
+		sub := st.Subscribe(10)
+		defer sub.Close()
+		So(st.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/a"), []byte("ONE"))
+			c.Put(2, []byte("/a"), []byte("TWO"))
+			c.Put(1, []byte("/b"), []byte("B"))
+			return nil
+		}), ShouldBeNil)
+		So(st.Update(func(c *Copy[[]byte]) error {
+			c.Del(3, []byte("/a"))
+			c.Del(3, []byte("/a"))
+			c.Del(3, []byte("/x"))
+			c.Cut([]byte("/b"))
+			c.Cut([]byte("/b"))
+			return nil
+		}), ShouldBeNil)
+		So(st.Update(func(c *Copy[[]byte]) error {
+			return nil
+		}), ShouldBeNil)
+		cs := <-sub.C
+		So(cs.Tree.Size(), ShouldEqual, 2)
+		So(cs.Changes, ShouldResemble, []Change[[]byte]{
+			{Op: OpPut, Key: []byte("/a"), Ver: 1, New: []byte("ONE")},
+			{Op: OpPut, Key: []byte("/a"), Ver: 2, Old: []byte("ONE"), New: []byte("TWO")},
+			{Op: OpPut, Key: []byte("/b"), Ver: 1, New: []byte("B")},
+		})
+		cs = <-sub.C
+		So(cs.Tree.Size(), ShouldEqual, 1)
+		So(cs.Changes, ShouldResemble, []Change[[]byte]{
+			{Op: OpDel, Key: []byte("/a"), Ver: 3, Old: []byte("TWO")},
+			{Op: OpCut, Key: []byte("/b"), Old: []byte("B")},
+		})
+		So(len(sub.C), ShouldEqual, 0)
+	})
+
+	Convey("Can receive the changes of range operations in key order", t, func() {
+		st := NewStore[[]byte](nil)
+		So(st.Update(func(c *Copy[[]byte]) error {
+			for _, v := range s[1:8] {
+				c.Put(1, []byte(v), []byte(v))
+			}
+			return nil
+		}), ShouldBeNil)
+		sub := st.Subscribe(10)
+		defer sub.Close()
+		So(st.Update(func(c *Copy[[]byte]) error {
+			c.Del(1, []byte(s[5]))
+			c.DelPrefix(2, []byte("/test/one/sub-one"))
+			c.CutPrefix([]byte("/test/one/sub-two"))
+			c.CutRange([]byte("/test/one/"), []byte("/test/one/sub-one/2"))
+			return nil
+		}), ShouldBeNil)
+		cs := <-sub.C
+		So(cs.Changes, ShouldResemble, []Change[[]byte]{
+			{Op: OpDel, Key: []byte(s[5]), Ver: 1, Old: []byte(s[5])},
+			{Op: OpDel, Key: []byte(s[3]), Ver: 2, Old: []byte(s[3])},
+			{Op: OpDel, Key: []byte(s[4]), Ver: 2, Old: []byte(s[4])},
+			{Op: OpCut, Key: []byte(s[6]), Old: []byte(s[6])},
+			{Op: OpCut, Key: []byte(s[7]), Old: []byte(s[7])},
+			{Op: OpCut, Key: []byte(s[3])},
+			{Op: OpCut, Key: []byte(s[4])},
+		})
+	})
+
+	Convey("Can not receive discarded or rolled back changes", t, func() {
+		st := NewStore[[]byte](nil)
+		sub := st.Subscribe(10)
+		defer sub.Close()
+		So(st.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/a"), []byte("A"))
+			return errors.New("discarded")
+		}), ShouldNotBeNil)
+		So(st.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/a"), []byte("A"))
+			sp := c.Savepoint()
+			c.Put(1, []byte("/b"), []byte("B"))
+			if err := c.RollbackTo(sp); err != nil {
+				return err
+			}
+			n := c.Begin()
+			n.Put(1, []byte("/c"), []byte("C"))
+			if err := n.Rollback(); err != nil {
+				return err
+			}
+			n = c.Begin()
+			n.Put(1, []byte("/d"), []byte("D"))
+			return n.Commit()
+		}), ShouldBeNil)
+		cs := <-sub.C
+		So(cs.Changes, ShouldResemble, []Change[[]byte]{
+			{Op: OpPut, Key: []byte("/a"), Ver: 1, New: []byte("A")},
+			{Op: OpPut, Key: []byte("/d"), Ver: 1, New: []byte("D")},
+		})
+		So(len(sub.C), ShouldEqual, 0)
+	})
+
+	Convey("Can signal when a subscriber falls behind", t, func() {
+		st := NewStore[[]byte](nil)
+		sub := st.Subscribe(1)
+		for _, k := range []string{"/a", "/b", "/c"} {
+			key := []byte(k)
+			So(st.Update(func(c *Copy[[]byte]) error {
+				c.Put(1, key, key)
+				return nil
+			}), ShouldBeNil)
+		}
+		cs, ok := <-sub.C
+		So(ok, ShouldBeTrue)
+		So(cs.Changes[0].Key, ShouldResemble, []byte("/a"))
+		_, ok = <-sub.C
+		So(ok, ShouldBeFalse)
+		So(sub.Err(), ShouldEqual, ErrOverflow)
+		sub.Close()
+		So(sub.Err(), ShouldEqual, ErrOverflow)
+	})
+
+	Convey("Can close a subscription", t, func() {
+		st := NewStore[[]byte](nil)
+		sub := st.Subscribe(1)
+		sub.Close()
+		sub.Close()
+		_, ok := <-sub.C
+		So(ok, ShouldBeFalse)
+		So(sub.Err(), ShouldBeNil)
+		So(st.Update(func(c *Copy[[]byte]) error {
+			So(c.track, ShouldBeFalse)
+			c.Put(1, []byte("/a"), nil)
+			return nil
+		}), ShouldBeNil)
+	})
+
+	Convey("Can receive the changes of a database commit", t, func() {
+		db, err := Open(t.TempDir())
+		So(err, ShouldBeNil)
+		sub := db.Subscribe(1)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/a"), []byte("A"))
+			return nil
+		}), ShouldBeNil)
+		cs := <-sub.C
+		So(cs.Changes, ShouldHaveLength, 1)
+		So(db.Close(), ShouldBeNil)
+	})
+
+}
@@ -1,11 +1,8 @@
 module github.com/surrealdb/vtree
 
-go 1.17
+go 1.23
 
-require (
-	github.com/smartystreets/goconvey v1.7.2
-	github.com/surrealdb/tlist v0.0.0-20211208111707-2bbefa48e0ac
-)
+require github.com/smartystreets/goconvey v1.7.2
 
 require (
 	github.com/gopherjs/gopherjs v0.0.0-20181017120253-0766667cb4d1 // indirect
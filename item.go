@@ -15,64 +15,57 @@
 package vtree
 
 import (
-	"github.com/surrealdb/tlist"
+	"iter"
 )
 
 // Item represents a collection of versions and values, stored
-// in order of version number.
-type Item struct {
-	pntr *tlist.List
+// in order of version number. The versions are held in a
+// persistent list, so that an Item reachable from a committed
+// Tree is never modified by a subsequent Copy. Values are of
+// type V, and are stored without being copied or encoded.
+type Item[V any] struct {
+	list *elem[V]
 }
 
-func newItem() *Item {
-	return &Item{
-		pntr: tlist.New(),
-	}
-}
-
-// Put inserts a value with the specified version number. It
-// returns the previous value, or nil if it does not exist.
-func (i *Item) Put(ver uint64, val []byte) []byte {
-	if v := i.pntr.Put(ver, val); v != nil {
-		return v.Val()
-	}
-	return nil
+func newItem[V any]() *Item[V] {
+	return &Item[V]{}
 }
 
 // Get selects a value with the specified version number, or
 // the nearest latest value prior to the specified version.
 // If '0' is specified for the version, then the latest item
-// will be returned.
-func (i *Item) Get(ver uint64) []byte {
-	if v := i.pntr.Get(ver, tlist.Upto); v != nil {
-		return v.Val()
+// will be returned. If the value was deleted at or prior to
+// the specified version, then the zero value is returned.
+func (i *Item[V]) Get(ver uint64) (val V) {
+	if v := i.list.upto(ver); v != nil && !v.dead {
+		return v.val
 	}
-	return nil
+	return
 }
 
-// Del deletes a value with the specified version number, or
-// the nearest latest value prior to the specified version.
-func (i *Item) Del(ver uint64) []byte {
-	if v := i.pntr.Del(ver, tlist.Upto); v != nil {
-		return v.Val()
+// Deleted returns whether the version visible at the specified
+// version number is a tombstone.
+func (i *Item[V]) Deleted(ver uint64) bool {
+	if v := i.list.upto(ver); v != nil {
+		return v.dead
 	}
-	return nil
+	return false
 }
 
 // Min returns the value of the minium version in the list.
-func (i *Item) Min() []byte {
-	if v := i.pntr.Min(); v != nil {
-		return v.Val()
+func (i *Item[V]) Min() (val V) {
+	if v := i.list.min(); v != nil {
+		return v.val
 	}
-	return nil
+	return
 }
 
 // Max returns the value of the maximum version in the list.
-func (i *Item) Max() []byte {
-	if v := i.pntr.Max(); v != nil {
-		return v.Val()
+func (i *Item[V]) Max() (val V) {
+	if v := i.list.max(); v != nil {
+		return v.val
 	}
-	return nil
+	return
 }
 
 // Seek searches for a value prior to the specified version
@@ -80,17 +73,136 @@ func (i *Item) Max() []byte {
 // is specified for the version, then the first item will be
 // returned, and if math.MaxInt64 is used then the latest item
 // will be returned.
-func (i *Item) Seek(ver uint64) (uint64, []byte) {
-	if v := i.pntr.Get(ver, tlist.Upto); v != nil {
-		return v.Ver(), v.Val()
+func (i *Item[V]) Seek(ver uint64) (_ uint64, val V) {
+	if v := i.list.upto(ver); v != nil {
+		return v.ver, v.val
 	}
-	return 0, nil
+	return 0, val
 }
 
 // Walk iterates through all of the versions and values in the
-// list, in order of version, starting at the first version.
-func (i *Item) Walk(fn func(ver uint64, val []byte) bool) {
-	i.pntr.Walk(func(i *tlist.Item) bool {
-		return fn(i.Ver(), i.Val())
+// list, in order of version, starting at the first version. The
+// del flag is set for versions which are tombstones.
+func (i *Item[V]) Walk(fn func(ver uint64, val V, del bool) bool) {
+	i.list.walk(func(e *elem[V]) bool {
+		return fn(e.ver, e.val, e.dead)
 	})
 }
+
+// WalkReverse iterates through all of the versions and values in
+// the list, in reverse order of version, starting at the last
+// version. The del flag is set for versions which are tombstones.
+func (i *Item[V]) WalkReverse(fn func(ver uint64, val V, del bool) bool) {
+	i.list.rwalk(func(e *elem[V]) bool {
+		return fn(e.ver, e.val, e.dead)
+	})
+}
+
+// Range iterates through the versions and values in the list which
+// are greater than or equal to from, and less than or equal to to,
+// in order of version. The del flag is set for versions which are
+// tombstones.
+func (i *Item[V]) Range(from, to uint64, fn func(ver uint64, val V, del bool) bool) {
+	i.list.span(from, to, func(e *elem[V]) bool {
+		return fn(e.ver, e.val, e.dead)
+	})
+}
+
+// Since returns a new item containing only the versions which are
+// newer than the specified version. The versions are shared with
+// this item, which is left untouched.
+func (i *Item[V]) Since(ver uint64) *Item[V] {
+	return &Item[V]{list: i.list.since(ver)}
+}
+
+// Len returns the number of versions in the list, including any
+// versions which are tombstones.
+func (i *Item[V]) Len() int {
+	return i.list.len()
+}
+
+// Next returns the version and value immediately after the specified
+// version number, so that successive calls step through the versions
+// in ascending order. The del flag is set for versions which are
+// tombstones. If there is no later version, then ok is false.
+func (i *Item[V]) Next(ver uint64) (_ uint64, val V, del, ok bool) {
+	if v := i.list.next(ver); v != nil {
+		return v.ver, v.val, v.dead, true
+	}
+	return 0, val, false, false
+}
+
+// Prev returns the version and value immediately before the specified
+// version number, so that successive calls step through the versions
+// in descending order. The del flag is set for versions which are
+// tombstones. If there is no earlier version, then ok is false.
+func (i *Item[V]) Prev(ver uint64) (_ uint64, val V, del, ok bool) {
+	if v := i.list.prev(ver); v != nil {
+		return v.ver, v.val, v.dead, true
+	}
+	return 0, val, false, false
+}
+
+// Versions returns an iterator over the versions and values in the
+// list, in ascending order of version. Tombstones are skipped, so use
+// Walk to iterate over them too.
+func (i *Item[V]) Versions() iter.Seq2[uint64, V] {
+	return func(yield func(uint64, V) bool) {
+		i.list.walk(func(e *elem[V]) bool {
+			return !e.dead && !yield(e.ver, e.val)
+		})
+	}
+}
+
+// VersionsBackward returns an iterator over the versions and values in
+// the list, in descending order of version. Tombstones are skipped, so
+// use WalkReverse to iterate over them too.
+func (i *Item[V]) VersionsBackward() iter.Seq2[uint64, V] {
+	return func(yield func(uint64, V) bool) {
+		i.list.rwalk(func(e *elem[V]) bool {
+			return !e.dead && !yield(e.ver, e.val)
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+// live returns whether a value, and not a tombstone, is visible
+// at the specified version number.
+func (i *Item[V]) live(ver uint64) bool {
+	v := i.list.upto(ver)
+	return v != nil && !v.dead
+}
+
+// put returns a copy of the item with the value inserted at the
+// specified version, leaving the original item untouched.
+func (i *Item[V]) put(ver uint64, val V) *Item[V] {
+	return &Item[V]{list: i.list.put(ver, val)}
+}
+
+// tomb returns a copy of the item with a tombstone written at the
+// specified version, even if no value is visible at that version,
+// along with the previous value.
+func (i *Item[V]) tomb(ver uint64) (*Item[V], V) {
+	return &Item[V]{list: i.list.tomb(ver)}, i.Get(ver)
+}
+
+// del returns a copy of the item with a tombstone written at the
+// specified version, along with the previous value. If no value
+// exists at the specified version, the original item is returned.
+func (i *Item[V]) del(ver uint64) (_ *Item[V], old V) {
+	if v := i.list.upto(ver); v != nil && !v.dead {
+		return &Item[V]{list: i.list.tomb(ver)}, v.val
+	}
+	return i, old
+}
+
+// trim returns a copy of the item with all versions prior to the
+// version visible at the specified version removed, along with the
+// number of versions which were removed.
+func (i *Item[V]) trim(ver uint64) (*Item[V], int) {
+	if l := i.list.trim(ver); l != i.list {
+		return &Item[V]{list: l}, i.list.len() - l.len()
+	}
+	return i, 0
+}
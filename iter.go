@@ -16,6 +16,7 @@ package vtree
 
 import (
 	"bytes"
+	"sort"
 )
 
 // Cursor represents an iterator that can traverse over all key-value
@@ -24,21 +25,49 @@ import (
 // Changing data while traversing with a cursor may cause it to be
 // invalidated and return unexpected keys and/or values. You must
 // reposition your cursor after mutating data.
-type Cursor struct {
-	tree *Copy
+type Cursor[V any] struct {
+	tree *Copy[V]
 	seek []byte
-	path []*item
+	path []*item[V]
+	live bool
+	ver  uint64
+	txn  *Txn[V]
+	rng  *span
+	pre  []byte
 }
 
-type item struct {
+type item[V any] struct {
 	pos  int
-	node *Node
+	node *Node[V]
+}
+
+// Bounds describes a range of keys to iterate over using a cursor.
+// A nil Start or End leaves that side of the range unbounded. By
+// default the range includes the Start key and excludes the End key.
+type Bounds struct {
+	// Start is the lower bound of the range.
+	Start []byte
+	// End is the upper bound of the range.
+	End []byte
+	// StartExclusive excludes the Start key from the range.
+	StartExclusive bool
+	// EndInclusive includes the End key in the range.
+	EndInclusive bool
+	// Reverse iterates from the upper bound to the lower bound.
+	Reverse bool
+	// Limit is the maximum number of items to visit, or 0 for no limit.
+	Limit int
 }
 
 // Del removes the current item under the cursor from the tree. If
 // the cursor has not yet been positioned using First, Last, or Seek,
-// then no item is deleted and a nil key and value are returned.
-func (c *Cursor) Del() ([]byte, interface{}) {
+// then no item is deleted and a nil key and the zero value are
+// returned.
+func (c *Cursor[V]) Del() ([]byte, V) {
+
+	if c.txn != nil {
+		return c.seek, c.txn.Del(0, c.seek)
+	}
 
 	val := c.tree.Del(0, c.seek)
 
@@ -46,25 +75,48 @@ func (c *Cursor) Del() ([]byte, interface{}) {
 
 }
 
+// Live configures the cursor to skip over any item where the version
+// visible at the specified version number is a tombstone. It returns
+// the cursor so that it can be chained when the cursor is created.
+func (c *Cursor[V]) Live(ver uint64) *Cursor[V] {
+
+	c.live, c.ver = true, ver
+
+	return c
+
+}
+
 // First moves the cursor to the first item in the tree and returns
 // its key and value. If the tree is empty then a nil key and value
 // are returned.
-func (c *Cursor) First() ([]byte, *Item) {
+func (c *Cursor[V]) First() ([]byte, *Item[V]) {
 
 	c.path = nil
 
-	return c.first(c.tree.root)
+	if c.rng != nil {
+		c.rng.neg = true
+	}
+
+	n, _ := c.scope()
+
+	return c.fwd(c.first(n))
 
 }
 
 // Last moves the cursor to the last item in the tree and returns its
 // key and value. If the tree is empty then a nil key and value are
 // returned.
-func (c *Cursor) Last() ([]byte, *Item) {
+func (c *Cursor[V]) Last() ([]byte, *Item[V]) {
 
 	c.path = nil
 
-	return c.last(c.tree.root)
+	if c.rng != nil {
+		c.rng.pos = true
+	}
+
+	n, _ := c.scope()
+
+	return c.bwd(c.last(n))
 
 }
 
@@ -73,7 +125,253 @@ func (c *Cursor) Last() ([]byte, *Item) {
 // returned, and if the cursor is at the start of the tree then a nil key
 // and value are returned. If the cursor has not yet been positioned
 // using First, Last, or Seek, then a nil key and value are returned.
-func (c *Cursor) Prev() ([]byte, *Item) {
+func (c *Cursor[V]) Prev() ([]byte, *Item[V]) {
+
+	return c.bwd(c.prev())
+
+}
+
+// Next moves the cursor to the next item in the tree and returns its
+// key and value. If the tree is empty then a nil key and value are
+// returned, and if the cursor is at the end of the tree then a nil key
+// and value are returned. If the cursor has not yet been positioned
+// using First, Last, or Seek, then a nil key and value are returned.
+func (c *Cursor[V]) Next() ([]byte, *Item[V]) {
+
+	return c.fwd(c.next())
+
+}
+
+// Seek moves the cursor to a given key in the tree and returns it.
+// If the specified key does not exist then the next key in the tree
+// is used. If no keys follow, then a nil key and value are returned.
+func (c *Cursor[V]) Seek(key []byte) ([]byte, *Item[V]) {
+
+	if c.rng != nil {
+		c.rng.add(key)
+	}
+
+	return c.fwd(c.find(key))
+
+}
+
+// SeekLE moves the cursor to the greatest key in the tree which is less
+// than or equal to the given key, and returns it. If no keys precede the
+// given key, then a nil key and value are returned.
+func (c *Cursor[V]) SeekLE(key []byte) ([]byte, *Item[V]) {
+
+	if c.rng != nil {
+		c.rng.add(key)
+	}
+
+	return c.bwd(c.upto(key))
+
+}
+
+// SeekLT moves the cursor to the greatest key in the tree which is less
+// than the given key, and returns it. If no keys precede the given key,
+// then a nil key and value are returned.
+func (c *Cursor[V]) SeekLT(key []byte) ([]byte, *Item[V]) {
+
+	if c.rng != nil {
+		c.rng.add(key)
+	}
+
+	k, v := c.upto(key)
+
+	if k != nil && bytes.Equal(k, key) {
+		k, v = c.prev()
+	}
+
+	return c.bwd(k, v)
+
+}
+
+// SeekIndex moves the cursor to the key at the specified index, in
+// ascending key order, and returns it. For cursors limited to a prefix,
+// the index is relative to the first key with that prefix. If the index
+// is out of range, then a nil key and value are returned.
+func (c *Cursor[V]) SeekIndex(i int) ([]byte, *Item[V]) {
+
+	if c.rng != nil {
+		c.rng.neg = true
+	}
+
+	return c.fwd(c.index(i))
+
+}
+
+// Range moves the cursor through all of the items within the bounds,
+// calling the specified Walker for each item, in ascending key order,
+// or in descending key order if the bounds are reversed. Iteration
+// stops once the limit is reached, or when the Walker returns true.
+func (c *Cursor[V]) Range(b Bounds, f Walker[V]) {
+
+	var k []byte
+	var v *Item[V]
+
+	switch {
+	case b.Reverse && b.End == nil:
+		k, v = c.Last()
+	case b.Reverse && b.EndInclusive:
+		k, v = c.SeekLE(b.End)
+	case b.Reverse:
+		k, v = c.SeekLT(b.End)
+	case b.Start == nil:
+		k, v = c.First()
+	default:
+		k, v = c.Seek(b.Start)
+		if b.StartExclusive && bytes.Equal(k, b.Start) {
+			k, v = c.Next()
+		}
+	}
+
+	for n := 0; k != nil; n++ {
+
+		if b.Limit > 0 && n >= b.Limit {
+			return
+		}
+
+		if b.Reverse && b.Start != nil {
+			if x := bytes.Compare(k, b.Start); x < 0 || x == 0 && b.StartExclusive {
+				return
+			}
+		}
+
+		if !b.Reverse && b.End != nil {
+			if x := bytes.Compare(k, b.End); x > 0 || x == 0 && !b.EndInclusive {
+				return
+			}
+		}
+
+		if f(k, v) {
+			return
+		}
+
+		if b.Reverse {
+			k, v = c.Prev()
+		} else {
+			k, v = c.Next()
+		}
+
+	}
+
+}
+
+// ------
+
+// upto moves the cursor to the greatest key which is less than
+// or equal to the given key, and returns its key and value.
+func (c *Cursor[V]) upto(key []byte) ([]byte, *Item[V]) {
+
+	n, pre := c.scope()
+
+	c.path = nil
+
+	// Check the key is within the scope
+	if !bytes.HasPrefix(key, pre) {
+		if bytes.Compare(key, pre) < 0 {
+			return nil, nil
+		}
+		return c.last(n)
+	}
+
+	s := key[len(pre):]
+
+	var x int
+
+	for {
+
+		// Check for key exhaution
+		if len(s) == 0 {
+			if n.isLeaf() {
+				c.seek = n.leaf.key
+				return n.leaf.key, n.leaf.val
+			}
+			return c.prev()
+		}
+
+		t := n
+
+		// Look for an edge
+		if x, n = n.getSub(s[0]); n == nil {
+
+			// Find the last edge before the key
+			x = sort.Search(len(t.edges), func(i int) bool {
+				return t.edges[i].prefix[0] > s[0]
+			}) - 1
+
+			// Use this node if none precede
+			if x < 0 {
+				if t.isLeaf() {
+					c.seek = t.leaf.key
+					return t.leaf.key, t.leaf.val
+				}
+				return c.prev()
+			}
+
+			c.path = append(c.path, &item[V]{pos: x, node: t})
+
+			return c.last(t.edges[x])
+
+		}
+
+		c.path = append(c.path, &item[V]{pos: x, node: t})
+
+		// Consume the search prefix
+		if bytes.HasPrefix(s, n.prefix) {
+			s = s[len(n.prefix):]
+			continue
+		}
+
+		// The whole subtree follows the key
+		if bytes.Compare(s, n.prefix) < 0 {
+			return c.prev()
+		}
+
+		// The whole subtree precedes the key
+		return c.last(n)
+
+	}
+
+}
+
+// index moves the cursor to the key at the specified index, using
+// the leaf counts of each node to descend directly to the key.
+func (c *Cursor[V]) index(i int) ([]byte, *Item[V]) {
+
+	n, _ := c.scope()
+
+	c.path = nil
+
+	if i < 0 || i >= n.size {
+		return nil, nil
+	}
+
+	for {
+
+		if n.isLeaf() {
+			if i == 0 {
+				c.seek = n.leaf.key
+				return n.leaf.key, n.leaf.val
+			}
+			i--
+		}
+
+		for x, e := range n.edges {
+			if i < e.size {
+				c.path = append(c.path, &item[V]{pos: x, node: n})
+				n = e
+				break
+			}
+			i -= e.size
+		}
+
+	}
+
+}
+
+func (c *Cursor[V]) prev() ([]byte, *Item[V]) {
 
 OUTER:
 	for {
@@ -130,7 +428,7 @@ OUTER:
 				for {
 
 					if num := len(n.edges); num > 0 {
-						c.path = append(c.path, &item{pos: num - 1, node: n})
+						c.path = append(c.path, &item[V]{pos: num - 1, node: n})
 						n = n.edges[num-1]
 						continue
 					}
@@ -154,12 +452,7 @@ OUTER:
 
 }
 
-// Next moves the cursor to the next item in the tree and returns its
-// key and value. If the tree is empty then a nil key and value are
-// returned, and if the cursor is at the end of the tree then a nil key
-// and value are returned. If the cursor has not yet been positioned
-// using First, Last, or Seek, then a nil key and value are returned.
-func (c *Cursor) Next() ([]byte, *Item) {
+func (c *Cursor[V]) next() ([]byte, *Item[V]) {
 
 OUTER:
 	for {
@@ -178,7 +471,7 @@ OUTER:
 
 			if len(n.edges) > 0 {
 
-				c.path = append(c.path, &item{pos: 0, node: n})
+				c.path = append(c.path, &item[V]{pos: 0, node: n})
 				n = n.edges[0]
 
 				if n.isLeaf() {
@@ -235,17 +528,22 @@ OUTER:
 
 }
 
-// Seek moves the cursor to a given key in the tree and returns it.
-// If the specified key does not exist then the next key in the tree
-// is used. If no keys follow, then a nil key and value are returned.
-func (c *Cursor) Seek(key []byte) ([]byte, *Item) {
+func (c *Cursor[V]) find(key []byte) ([]byte, *Item[V]) {
 
-	s := key
-
-	n := c.tree.root
+	n, pre := c.scope()
 
 	c.path = nil
 
+	// Check the key is within the scope
+	if !bytes.HasPrefix(key, pre) {
+		if bytes.Compare(key, pre) < 0 {
+			return c.first(n)
+		}
+		return nil, nil
+	}
+
+	s := key[len(pre):]
+
 	var x int
 
 	// OUTER:
@@ -261,45 +559,44 @@ func (c *Cursor) Seek(key []byte) ([]byte, *Item) {
 		// Look for an edge
 		if x, n = n.getSub(s[0]); n == nil {
 
-			if len(t.edges) == 0 {
-				return c.Next()
-			} else if s[0] < t.edges[0].prefix[0] {
-				if len(c.path) == 0 {
-					return c.first(c.tree.root)
-				}
-				return c.first(c.path[len(c.path)-1].node)
-			} else if s[0] > t.edges[len(t.edges)-1].prefix[0] {
-				if len(c.path) == 0 {
-					break
-				}
-				return c.last(c.path[len(c.path)-1].node)
+			// Find the first edge after the key
+			x = sort.Search(len(t.edges), func(i int) bool {
+				return t.edges[i].prefix[0] > s[0]
+			})
+
+			// Move past this node if none follow
+			if x == len(t.edges) {
+				c.last(t)
+				return c.next()
 			}
 
-			break
+			c.path = append(c.path, &item[V]{pos: x, node: t})
+
+			return c.first(t.edges[x])
 
 		}
 
 		// Consume the search prefix
 		if bytes.Compare(s, n.prefix) == 0 {
-			c.path = append(c.path, &item{pos: x, node: t})
+			c.path = append(c.path, &item[V]{pos: x, node: t})
 			s = s[:0]
 			continue
 		} else if bytes.HasPrefix(s, n.prefix) {
-			c.path = append(c.path, &item{pos: x, node: t})
+			c.path = append(c.path, &item[V]{pos: x, node: t})
 			s = s[len(n.prefix):]
 			continue
 		} else if bytes.HasPrefix(n.prefix, s) {
-			c.path = append(c.path, &item{pos: x, node: t})
+			c.path = append(c.path, &item[V]{pos: x, node: t})
 			s = s[:0]
 			continue
 		} else if bytes.Compare(s, n.prefix) < 0 {
-			c.path = append(c.path, &item{pos: x, node: t})
+			c.path = append(c.path, &item[V]{pos: x, node: t})
 			s = s[:0]
 			continue
 		} else if bytes.Compare(s, n.prefix) > 0 {
-			c.path = append(c.path, &item{pos: x, node: t})
+			c.path = append(c.path, &item[V]{pos: x, node: t})
 			c.last(n)
-			return c.Next()
+			return c.next()
 		}
 
 		break
@@ -312,16 +609,97 @@ func (c *Cursor) Seek(key []byte) ([]byte, *Item) {
 
 }
 
-// ------
+// scope returns the node from which the cursor iterates, along with
+// the key prefix which precedes the prefixes of its edges. For cursors
+// limited to a prefix, this is a detached node whose only edge is the
+// subtree containing the prefix, so that iteration ends at its bounds.
+func (c *Cursor[V]) scope() (*Node[V], []byte) {
+
+	if c.pre == nil {
+		return c.tree.root, nil
+	}
+
+	n := c.tree.root
+
+	s := c.pre
+
+	var par, abs []byte
+
+	for len(s) > 0 {
+
+		// Look for an edge
+		_, e := n.getSub(s[0])
+		if e == nil {
+			return &Node[V]{}, c.pre
+		}
+
+		// Consume the search prefix
+		if bytes.HasPrefix(s, e.prefix) {
+			s = s[len(e.prefix):]
+		} else if bytes.HasPrefix(e.prefix, s) {
+			s = s[:0]
+		} else {
+			return &Node[V]{}, c.pre
+		}
+
+		par, abs = abs, concat(abs, e.prefix)
+
+		n = e
+
+	}
+
+	if n == c.tree.root {
+		return n, nil
+	}
+
+	return &Node[V]{edges: []*Node[V]{n}, size: n.size}, par
+
+}
+
+func (c *Cursor[V]) fwd(k []byte, v *Item[V]) ([]byte, *Item[V]) {
+
+	for c.live && v != nil && v.Deleted(c.ver) {
+		k, v = c.next()
+	}
+
+	if c.rng != nil {
+		if k == nil {
+			c.rng.pos = true
+		} else {
+			c.rng.add(k)
+		}
+	}
+
+	return k, v
+
+}
+
+func (c *Cursor[V]) bwd(k []byte, v *Item[V]) ([]byte, *Item[V]) {
+
+	for c.live && v != nil && v.Deleted(c.ver) {
+		k, v = c.prev()
+	}
+
+	if c.rng != nil {
+		if k == nil {
+			c.rng.neg = true
+		} else {
+			c.rng.add(k)
+		}
+	}
+
+	return k, v
+
+}
 
-func (c *Cursor) node() *Node {
+func (c *Cursor[V]) node() *Node[V] {
 
 	var x int
 
 	x = len(c.path) - 1
 
 	if len(c.path[x].node.edges) <= c.path[x].pos {
-		c.Seek(c.seek)
+		c.find(c.seek)
 		x = len(c.path) - 1
 	}
 
@@ -329,7 +707,7 @@ func (c *Cursor) node() *Node {
 
 }
 
-func (c *Cursor) first(n *Node) ([]byte, *Item) {
+func (c *Cursor[V]) first(n *Node[V]) ([]byte, *Item[V]) {
 
 	for {
 
@@ -339,7 +717,7 @@ func (c *Cursor) first(n *Node) ([]byte, *Item) {
 		}
 
 		if len(n.edges) > 0 {
-			c.path = append(c.path, &item{pos: 0, node: n})
+			c.path = append(c.path, &item[V]{pos: 0, node: n})
 			n = n.edges[0]
 		} else {
 			break
@@ -351,12 +729,12 @@ func (c *Cursor) first(n *Node) ([]byte, *Item) {
 
 }
 
-func (c *Cursor) last(n *Node) ([]byte, *Item) {
+func (c *Cursor[V]) last(n *Node[V]) ([]byte, *Item[V]) {
 
 	for {
 
 		if num := len(n.edges); num > 0 {
-			c.path = append(c.path, &item{pos: num - 1, node: n})
+			c.path = append(c.path, &item[V]{pos: num - 1, node: n})
 			n = n.edges[num-1]
 			continue
 		}
@@ -0,0 +1,317 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+// elem represents an immutable node in a persistent version list.
+// The list is stored as a treap ordered by version, with a priority
+// derived from the version, so that any change only copies the path
+// from the root of the list to the changed element. A nil elem is
+// a valid empty list. A deleted version is stored as a tombstone.
+type elem[V any] struct {
+	ver  uint64
+	val  V
+	dead bool
+	size int
+	l, r *elem[V]
+}
+
+func (e *elem[V]) len() int {
+	if e == nil {
+		return 0
+	}
+	return e.size
+}
+
+func (e *elem[V]) dup() *elem[V] {
+	d := &elem[V]{}
+	*d = *e
+	return d
+}
+
+func (e *elem[V]) fix() *elem[V] {
+	e.size = 1 + e.l.len() + e.r.len()
+	return e
+}
+
+// prio returns a well distributed priority for a version, so that
+// versions inserted in ascending order still produce a balanced list.
+func prio(ver uint64) uint64 {
+	ver ^= ver >> 33
+	ver *= 0xff51afd7ed558ccd
+	ver ^= ver >> 33
+	ver *= 0xc4ceb9fe1a85ec53
+	ver ^= ver >> 33
+	return ver
+}
+
+// split divides the list into the versions less than the specified
+// version, and the versions greater than or equal to it.
+func split[V any](e *elem[V], ver uint64) (*elem[V], *elem[V]) {
+	if e == nil {
+		return nil, nil
+	}
+	d := e.dup()
+	if e.ver < ver {
+		l, r := split(e.r, ver)
+		d.r = l
+		return d.fix(), r
+	}
+	l, r := split(e.l, ver)
+	d.l = r
+	return l, d.fix()
+}
+
+// merge joins two lists, where every version in the first list is
+// less than every version in the second list.
+func merge[V any](a, b *elem[V]) *elem[V] {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	if prio(a.ver) > prio(b.ver) {
+		d := a.dup()
+		d.r = merge(a.r, b)
+		return d.fix()
+	}
+	d := b.dup()
+	d.l = merge(a, b.l)
+	return d.fix()
+}
+
+// build returns a new list from elements which are in ascending order
+// of version, constructing the list in linear time.
+func build[V any](es []*elem[V]) *elem[V] {
+	var stack []*elem[V]
+	for _, e := range es {
+		var last *elem[V]
+		for len(stack) > 0 && prio(stack[len(stack)-1].ver) < prio(e.ver) {
+			last, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		}
+		e.l = last
+		if len(stack) > 0 {
+			stack[len(stack)-1].r = e
+		}
+		stack = append(stack, e)
+	}
+	if len(stack) == 0 {
+		return nil
+	}
+	return stack[0].sum()
+}
+
+// sum recalculates the size of every element in the list.
+func (e *elem[V]) sum() *elem[V] {
+	if e != nil {
+		e.l.sum()
+		e.r.sum()
+		e.fix()
+	}
+	return e
+}
+
+// put returns a new list with the value set at the specified version.
+func (e *elem[V]) put(ver uint64, val V) *elem[V] {
+	return e.add(&elem[V]{ver: ver, val: val, size: 1})
+}
+
+// tomb returns a new list with a tombstone set at the specified version.
+func (e *elem[V]) tomb(ver uint64) *elem[V] {
+	return e.add(&elem[V]{ver: ver, dead: true, size: 1})
+}
+
+func (e *elem[V]) add(n *elem[V]) *elem[V] {
+	if e.exact(n.ver) != nil {
+		return e.set(n)
+	}
+	return e.ins(n)
+}
+
+// set returns a new list with an existing version replaced.
+func (e *elem[V]) set(n *elem[V]) *elem[V] {
+	d := e.dup()
+	switch {
+	case n.ver < e.ver:
+		d.l = e.l.set(n)
+	case n.ver > e.ver:
+		d.r = e.r.set(n)
+	default:
+		d.val, d.dead = n.val, n.dead
+	}
+	return d
+}
+
+// ins returns a new list with a version which does not yet exist.
+func (e *elem[V]) ins(n *elem[V]) *elem[V] {
+	if e == nil {
+		return n
+	}
+	if prio(n.ver) > prio(e.ver) {
+		n.l, n.r = split(e, n.ver)
+		return n.fix()
+	}
+	d := e.dup()
+	if n.ver < e.ver {
+		d.l = e.l.ins(n)
+	} else {
+		d.r = e.r.ins(n)
+	}
+	return d.fix()
+}
+
+// del returns a new list with the specified version removed.
+func (e *elem[V]) del(ver uint64) *elem[V] {
+	if e == nil {
+		return nil
+	}
+	switch {
+	case ver < e.ver:
+		d := e.dup()
+		d.l = e.l.del(ver)
+		return d.fix()
+	case ver > e.ver:
+		d := e.dup()
+		d.r = e.r.del(ver)
+		return d.fix()
+	}
+	return merge(e.l, e.r)
+}
+
+// trim returns a new list with all of the versions prior to the
+// greatest version less than or equal to the specified version
+// removed, so that the value visible at that version is retained.
+func (e *elem[V]) trim(ver uint64) *elem[V] {
+	if f := e.upto(ver); f != nil && f != e.min() {
+		_, r := split(e, f.ver)
+		return r
+	}
+	return e
+}
+
+// since returns a new list containing only the versions which are
+// strictly greater than the specified version.
+func (e *elem[V]) since(ver uint64) *elem[V] {
+	if m := e.max(); m == nil || m.ver <= ver {
+		return nil
+	}
+	_, r := split(e, ver+1)
+	return r
+}
+
+// exact returns the element with the specified version.
+func (e *elem[V]) exact(ver uint64) *elem[V] {
+	for e != nil {
+		switch {
+		case ver < e.ver:
+			e = e.l
+		case ver > e.ver:
+			e = e.r
+		default:
+			return e
+		}
+	}
+	return nil
+}
+
+// upto returns the element with the greatest version which is
+// less than or equal to the specified version.
+func (e *elem[V]) upto(ver uint64) (f *elem[V]) {
+	for e != nil {
+		if e.ver <= ver {
+			f, e = e, e.r
+		} else {
+			e = e.l
+		}
+	}
+	return
+}
+
+// prev returns the element with the greatest version which is
+// strictly less than the specified version.
+func (e *elem[V]) prev(ver uint64) (f *elem[V]) {
+	for e != nil {
+		if e.ver < ver {
+			f, e = e, e.r
+		} else {
+			e = e.l
+		}
+	}
+	return
+}
+
+// next returns the element with the smallest version which is
+// strictly greater than the specified version.
+func (e *elem[V]) next(ver uint64) (f *elem[V]) {
+	for e != nil {
+		if e.ver > ver {
+			f, e = e, e.l
+		} else {
+			e = e.r
+		}
+	}
+	return
+}
+
+func (e *elem[V]) min() *elem[V] {
+	if e != nil {
+		for e.l != nil {
+			e = e.l
+		}
+	}
+	return e
+}
+
+func (e *elem[V]) max() *elem[V] {
+	if e != nil {
+		for e.r != nil {
+			e = e.r
+		}
+	}
+	return e
+}
+
+// walk iterates through the list in order of version, and returns
+// true if the iteration was terminated by the callback function.
+func (e *elem[V]) walk(fn func(*elem[V]) bool) bool {
+	if e == nil {
+		return false
+	}
+	return e.l.walk(fn) || fn(e) || e.r.walk(fn)
+}
+
+// span iterates through the versions which are greater than or equal
+// to from, and less than or equal to to, in order of version, and
+// returns true if the iteration was terminated by the callback.
+func (e *elem[V]) span(from, to uint64, fn func(*elem[V]) bool) bool {
+	switch {
+	case e == nil:
+		return false
+	case e.ver < from:
+		return e.r.span(from, to, fn)
+	case e.ver > to:
+		return e.l.span(from, to, fn)
+	}
+	return e.l.span(from, to, fn) || fn(e) || e.r.span(from, to, fn)
+}
+
+// rwalk iterates through the list in reverse order of version, and
+// returns true if the iteration was terminated by the callback.
+func (e *elem[V]) rwalk(fn func(*elem[V]) bool) bool {
+	if e == nil {
+		return false
+	}
+	return e.r.rwalk(fn) || fn(e) || e.l.rwalk(fn)
+}
@@ -21,20 +21,22 @@ import (
 
 // Node represents an immutable node in the radix tree which
 // can be either an edge node or a leaf node.
-type Node struct {
-	leaf   *leaf
-	edges  []*Node
+type Node[V any] struct {
+	leaf   *leaf[V]
+	edges  []*Node[V]
 	prefix []byte
+	size   int
+	gen    uint64
 }
 
-type leaf struct {
+type leaf[V any] struct {
 	key []byte
-	val *Item
+	val *Item[V]
 }
 
 // Min returns the key and value of the minimum item in the
 // subtree of the current node.
-func (n *Node) Min() ([]byte, *Item) {
+func (n *Node[V]) Min() ([]byte, *Item[V]) {
 
 	for {
 
@@ -56,7 +58,7 @@ func (n *Node) Min() ([]byte, *Item) {
 
 // Max returns the key and value of the maximum item in the
 // subtree of the current node.
-func (n *Node) Max() ([]byte, *Item) {
+func (n *Node[V]) Max() ([]byte, *Item[V]) {
 
 	for {
 
@@ -79,7 +81,7 @@ func (n *Node) Max() ([]byte, *Item) {
 
 // Path is used to recurse over the tree only visiting nodes
 // which are above this node in the tree.
-func (n *Node) Path(k []byte, f Walker) {
+func (n *Node[V]) Path(k []byte, f Walker[V]) {
 
 	s := k
 
@@ -111,7 +113,7 @@ func (n *Node) Path(k []byte, f Walker) {
 
 // Subs is used to recurse over the tree only visiting nodes
 // which are directly under this node in the tree.
-func (n *Node) Subs(k []byte, f Walker) {
+func (n *Node[V]) Subs(k []byte, f Walker[V]) {
 
 	s := k
 
@@ -144,7 +146,7 @@ func (n *Node) Subs(k []byte, f Walker) {
 
 // Walk is used to recurse over the tree only visiting nodes
 // which are under this node in the tree.
-func (n *Node) Walk(k []byte, f Walker) {
+func (n *Node[V]) Walk(k []byte, f Walker[V]) {
 
 	s := k
 
@@ -181,14 +183,27 @@ func (n *Node) Walk(k []byte, f Walker) {
 // ------------------------------
 // ------------------------------
 
-func (n *Node) isLeaf() bool {
+func (n *Node[V]) isLeaf() bool {
 	return n.leaf != nil
 }
 
-func (n *Node) dup() *Node {
-	d := &Node{}
+// count recalculates the number of leaves in the subtree of
+// the node, from the leaf and the counts of the child nodes.
+func (n *Node[V]) count() *Node[V] {
+	n.size = 0
 	if n.leaf != nil {
-		d.leaf = &leaf{}
+		n.size++
+	}
+	for _, e := range n.edges {
+		n.size += e.size
+	}
+	return n
+}
+
+func (n *Node[V]) dup() *Node[V] {
+	d := &Node[V]{size: n.size}
+	if n.leaf != nil {
+		d.leaf = &leaf[V]{}
 		*d.leaf = *n.leaf
 	}
 	if n.prefix != nil {
@@ -196,13 +211,13 @@ func (n *Node) dup() *Node {
 		copy(d.prefix, n.prefix)
 	}
 	if len(n.edges) != 0 {
-		d.edges = make([]*Node, len(n.edges))
+		d.edges = make([]*Node[V], len(n.edges))
 		copy(d.edges, n.edges)
 	}
 	return d
 }
 
-func (n *Node) addSub(s *Node) {
+func (n *Node[V]) addSub(s *Node[V]) {
 	num := len(n.edges)
 	idx := sort.Search(num, func(i int) bool {
 		return n.edges[i].prefix[0] >= s.prefix[0]
@@ -214,7 +229,7 @@ func (n *Node) addSub(s *Node) {
 	}
 }
 
-func (n *Node) repSub(s *Node) {
+func (n *Node[V]) repSub(s *Node[V]) {
 	num := len(n.edges)
 	idx := sort.Search(num, func(i int) bool {
 		return n.edges[i].prefix[0] >= s.prefix[0]
@@ -226,7 +241,7 @@ func (n *Node) repSub(s *Node) {
 	panic("replacing missing edge")
 }
 
-func (n *Node) getSub(label byte) (int, *Node) {
+func (n *Node[V]) getSub(label byte) (int, *Node[V]) {
 	num := len(n.edges)
 	idx := sort.Search(num, func(i int) bool {
 		return n.edges[i].prefix[0] >= label
@@ -237,7 +252,7 @@ func (n *Node) getSub(label byte) (int, *Node) {
 	return -1, nil
 }
 
-func (n *Node) delSub(label byte) {
+func (n *Node[V]) delSub(label byte) {
 	num := len(n.edges)
 	idx := sort.Search(num, func(i int) bool {
 		return n.edges[i].prefix[0] >= label
@@ -249,25 +264,26 @@ func (n *Node) delSub(label byte) {
 	}
 }
 
-func (n *Node) mergeChild() {
+func (n *Node[V]) mergeChild() {
 	e := n.edges[0]
 	child := e
 	n.prefix = concat(n.prefix, child.prefix)
 	if child.leaf != nil {
-		n.leaf = new(leaf)
+		n.leaf = new(leaf[V])
 		*n.leaf = *child.leaf
 	} else {
 		n.leaf = nil
 	}
 	if len(child.edges) != 0 {
-		n.edges = make([]*Node, len(child.edges))
+		n.edges = make([]*Node[V], len(child.edges))
 		copy(n.edges, child.edges)
 	} else {
 		n.edges = nil
 	}
+	n.size = child.size
 }
 
-func subs(n *Node, f Walker, sub bool) bool {
+func subs[V any](n *Node[V], f Walker[V], sub bool) bool {
 
 	// Visit the leaf values if any
 	if sub && n.leaf != nil {
@@ -288,7 +304,7 @@ func subs(n *Node, f Walker, sub bool) bool {
 
 }
 
-func walk(n *Node, f Walker, sub bool) bool {
+func walk[V any](n *Node[V], f Walker[V], sub bool) bool {
 
 	// Visit the leaf values if any
 	if n.leaf != nil {
@@ -308,7 +324,35 @@ func walk(n *Node, f Walker, sub bool) bool {
 
 }
 
-func (n *Node) get(k []byte) *Item {
+// sub returns the node whose subtree contains exactly those keys
+// which begin with the given prefix, or nil if there are none.
+func (n *Node[V]) sub(k []byte) *Node[V] {
+
+	s := k
+
+	for len(s) > 0 {
+
+		// Look for an edge
+		if _, n = n.getSub(s[0]); n == nil {
+			return nil
+		}
+
+		// Consume the search prefix
+		if bytes.HasPrefix(s, n.prefix) {
+			s = s[len(n.prefix):]
+		} else if bytes.HasPrefix(n.prefix, s) {
+			break
+		} else {
+			return nil
+		}
+
+	}
+
+	return n
+
+}
+
+func (n *Node[V]) get(k []byte) *Item[V] {
 
 	s := k
 
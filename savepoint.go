@@ -0,0 +1,152 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"errors"
+)
+
+var (
+	// ErrSavepoint is returned when rolling back to, or releasing, a
+	// savepoint which was not taken on the copy, or which has already
+	// been released or rolled back past.
+	ErrSavepoint = errors.New("vtree: unknown savepoint")
+	// ErrNotNested is returned when committing or rolling back a copy
+	// which was not started with Copy.Begin.
+	ErrNotNested = errors.New("vtree: copy is not nested")
+)
+
+// Savepoint records the state of a Copy at a point in time, so that
+// any subsequent changes can be rolled back. Taking a savepoint is
+// cheap, as the nodes which are reachable at that time are shared
+// with the copy, and are copied before being modified.
+type Savepoint[V any] struct {
+	gen   uint64
+	root  *Node[V]
+	size  int
+	clock uint64
+	gone  *removal
+	ops   int
+	feed  int
+}
+
+// Savepoint records the current state of the copy, which can later
+// be restored with RollbackTo.
+func (c *Copy[V]) Savepoint() Savepoint[V] {
+	c.seal()
+	sp := Savepoint[V]{gen: c.gen, root: c.root, size: c.size, clock: c.clock, gone: c.gone, feed: len(c.feed)}
+	if c.log != nil {
+		sp.ops = c.log.Len()
+	}
+	c.saves = append(c.saves, sp)
+	return sp
+}
+
+// RollbackTo discards all of the changes made to the copy since the
+// savepoint was taken, along with any savepoints taken since. The
+// savepoint remains active, so that it can be rolled back to again.
+func (c *Copy[V]) RollbackTo(sp Savepoint[V]) error {
+	i := c.save(sp)
+	if i < 0 {
+		return ErrSavepoint
+	}
+	c.root, c.size = sp.root, sp.size
+	c.clock, c.gone = sp.clock, sp.gone
+	if c.log != nil {
+		c.log.ops = c.log.ops[:sp.ops]
+	}
+	c.feed = c.feed[:sp.feed]
+	c.saves = c.saves[:i+1]
+	return nil
+}
+
+// Release discards the savepoint, along with any savepoints taken
+// since, keeping all of the changes made to the copy.
+func (c *Copy[V]) Release(sp Savepoint[V]) error {
+	i := c.save(sp)
+	if i < 0 {
+		return ErrSavepoint
+	}
+	c.saves = c.saves[:i]
+	return nil
+}
+
+// Begin starts a nested copy of this copy. Changes made to the nested
+// copy are only applied to this copy when the nested copy is committed.
+// This copy must not be changed while the nested copy is in use.
+func (c *Copy[V]) Begin() *Copy[V] {
+	c.seal()
+	n := &Copy[V]{size: c.size, root: c.root, clock: c.clock, gone: c.gone, base: c.root, parent: c, track: c.track}
+	if c.log != nil {
+		n.log = &Batch[V]{}
+	}
+	n.seal()
+	return n
+}
+
+// Commit applies the changes made to a nested copy to its parent. It
+// returns ErrConflict if the parent has been changed since the nested
+// copy began, and ErrTxnClosed if the nested copy has already been
+// committed or rolled back.
+func (c *Copy[V]) Commit() error {
+	p, err := c.nested()
+	if err != nil {
+		return err
+	}
+	if p.root != c.base {
+		return ErrConflict
+	}
+	c.seal()
+	p.root, p.size = c.root, c.size
+	p.clock, p.gone = c.clock, c.gone
+	if p.log != nil {
+		p.log.ops = append(p.log.ops, c.log.ops...)
+	}
+	p.feed = append(p.feed, c.feed...)
+	c.done = true
+	return nil
+}
+
+// Rollback discards the changes made to a nested copy. It returns
+// ErrTxnClosed if the nested copy has already been committed or
+// rolled back.
+func (c *Copy[V]) Rollback() error {
+	if _, err := c.nested(); err != nil {
+		return err
+	}
+	c.done = true
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+
+func (c *Copy[V]) save(sp Savepoint[V]) int {
+	for i := len(c.saves) - 1; i >= 0; i-- {
+		if c.saves[i].gen == sp.gen {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Copy[V]) nested() (*Copy[V], error) {
+	if c.parent == nil {
+		return nil, ErrNotNested
+	}
+	if c.done {
+		return nil, ErrTxnClosed
+	}
+	return c.parent, nil
+}
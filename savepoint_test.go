@@ -0,0 +1,142 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSavepoint(t *testing.T) {
+
+	Convey("Can roll back to a savepoint", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/test"), []byte("ONE"))
+		c.Put(1, []byte("/keep"), []byte("KEEP"))
+		sp := c.Savepoint()
+		c.Put(1, []byte("/test"), []byte("TWO"))
+		c.Put(2, []byte("/test"), []byte("TRE"))
+		c.Put(1, []byte("/test/sub"), []byte("SUB"))
+		c.Cut([]byte("/keep"))
+		So(c.Size(), ShouldEqual, 2)
+		So(c.RollbackTo(sp), ShouldBeNil)
+		So(c.Size(), ShouldEqual, 2)
+		So(c.Get(1, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(c.Get(2, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(c.Get(1, []byte("/test/sub")), ShouldBeNil)
+		So(c.Get(1, []byte("/keep")), ShouldResemble, []byte("KEEP"))
+		c.Put(1, []byte("/test"), []byte("FOR"))
+		So(c.RollbackTo(sp), ShouldBeNil)
+		So(c.Get(1, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(sized(c.Root()), ShouldBeTrue)
+	})
+
+	Convey("Can roll back and release nested savepoints", t, func() {
+		c := New[[]byte]().Copy()
+		a := c.Savepoint()
+		c.Put(1, []byte("/a"), []byte("A"))
+		b := c.Savepoint()
+		c.Put(1, []byte("/b"), []byte("B"))
+		x := c.Savepoint()
+		c.Put(1, []byte("/c"), []byte("C"))
+		So(c.Release(x), ShouldBeNil)
+		So(c.Release(x), ShouldEqual, ErrSavepoint)
+		So(c.Size(), ShouldEqual, 3)
+		So(c.RollbackTo(b), ShouldBeNil)
+		So(c.Size(), ShouldEqual, 1)
+		So(c.RollbackTo(a), ShouldBeNil)
+		So(c.Size(), ShouldEqual, 0)
+		So(c.RollbackTo(b), ShouldEqual, ErrSavepoint)
+		So(c.Release(a), ShouldBeNil)
+		So(c.RollbackTo(a), ShouldEqual, ErrSavepoint)
+		So(New[[]byte]().Copy().RollbackTo(a), ShouldEqual, ErrSavepoint)
+	})
+
+	Convey("Can commit a nested copy into its parent", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/test"), []byte("ONE"))
+		n := c.Begin()
+		n.Put(1, []byte("/test"), []byte("TWO"))
+		n.Put(1, []byte("/nest"), []byte("NEST"))
+		So(c.Get(1, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(c.Size(), ShouldEqual, 1)
+		So(n.Commit(), ShouldBeNil)
+		So(c.Get(1, []byte("/test")), ShouldResemble, []byte("TWO"))
+		So(c.Get(1, []byte("/nest")), ShouldResemble, []byte("NEST"))
+		So(c.Size(), ShouldEqual, 2)
+		n.Put(1, []byte("/test"), []byte("TRE"))
+		So(c.Get(1, []byte("/test")), ShouldResemble, []byte("TWO"))
+		So(n.Commit(), ShouldEqual, ErrTxnClosed)
+		So(n.Rollback(), ShouldEqual, ErrTxnClosed)
+		So(c.Commit(), ShouldEqual, ErrNotNested)
+		So(c.Rollback(), ShouldEqual, ErrNotNested)
+	})
+
+	Convey("Can roll back a nested copy", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/test"), []byte("ONE"))
+		n := c.Begin()
+		n.Cut([]byte("/test"))
+		m := n.Begin()
+		m.Put(1, []byte("/deep"), []byte("DEEP"))
+		So(m.Commit(), ShouldBeNil)
+		So(n.Get(1, []byte("/deep")), ShouldResemble, []byte("DEEP"))
+		So(n.Rollback(), ShouldBeNil)
+		So(c.Get(1, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(c.Get(1, []byte("/deep")), ShouldBeNil)
+		So(c.Size(), ShouldEqual, 1)
+	})
+
+	Convey("Can not commit a nested copy when the parent has changed", t, func() {
+		c := New[[]byte]().Copy()
+		n := c.Begin()
+		c.Put(1, []byte("/test"), []byte("ONE"))
+		n.Put(1, []byte("/nest"), []byte("NEST"))
+		So(n.Commit(), ShouldEqual, ErrConflict)
+		So(c.Get(1, []byte("/nest")), ShouldBeNil)
+	})
+
+	Convey("Can only log changes which are not rolled back", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/a"), []byte("A"))
+			sp := c.Savepoint()
+			c.Put(1, []byte("/b"), []byte("B"))
+			c.Cut([]byte("/a"))
+			if err := c.RollbackTo(sp); err != nil {
+				return err
+			}
+			n := c.Begin()
+			n.Put(1, []byte("/c"), []byte("C"))
+			if err := n.Commit(); err != nil {
+				return err
+			}
+			n = c.Begin()
+			n.Put(1, []byte("/d"), []byte("D"))
+			return n.Rollback()
+		}), ShouldBeNil)
+		want := dump(db.Load())
+		So(want, ShouldResemble, []string{`/a@1="A"/false/false`, `/c@1="C"/false/false`})
+		So(db.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(dump(db.Load()), ShouldResemble, want)
+		So(db.Close(), ShouldBeNil)
+	})
+
+}
@@ -0,0 +1,403 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"slices"
+)
+
+// A snapshot, as written by Tree.WriteTo, is laid out as follows,
+// where every integer is encoded as an unsigned varint unless it is
+// otherwise stated:
+//
+//	magic       4 bytes   the characters "VTRE"
+//	format      1 byte    the snapshot format version, currently 1
+//	keys        varint    the number of keys in the tree
+//	for each key, in ascending order of key:
+//	  klen      varint    the length of the key
+//	  key       klen      the key
+//	  vers      varint    the number of versions of the key
+//	  for each version, in ascending order of version:
+//	    ver     varint    the version number
+//	    kind    1 byte    0 for a value, 1 for a tombstone, 2 for nil
+//	    vlen    varint    the length of the value, for values only
+//	    val     vlen      the value, for values only
+//	checksum    4 bytes   big-endian CRC-32C of all preceding bytes
+
+const (
+	snapFormat byte = 1
+)
+
+const (
+	kindVal byte = iota
+	kindDel
+	kindNil
+)
+
+var snapMagic = []byte("VTRE")
+
+var snapTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrSnapshotFormat is returned when reading a snapshot which
+	// was not written by Tree.WriteTo, or which is malformed.
+	ErrSnapshotFormat = errors.New("vtree: invalid snapshot format")
+	// ErrSnapshotVersion is returned when reading a snapshot which
+	// was written using an unsupported snapshot format version.
+	ErrSnapshotVersion = errors.New("vtree: unsupported snapshot version")
+	// ErrSnapshotChecksum is returned when reading a snapshot whose
+	// contents do not match the checksum at the end of the snapshot.
+	ErrSnapshotChecksum = errors.New("vtree: snapshot checksum mismatch")
+	// ErrValueType is returned when encoding or decoding the values
+	// of a tree, delta or batch whose values are not byte slices.
+	ErrValueType = errors.New("vtree: values are not byte slices")
+)
+
+// WriteTo writes a snapshot of the tree, including every version and
+// tombstone of every key, to the writer. The snapshot is streamed as
+// the tree is traversed. It returns the number of bytes written, and
+// returns ErrValueType if the values of the tree are not []byte.
+func (t *Tree[V]) WriteTo(w io.Writer) (int64, error) {
+
+	e := newEncoder(w)
+
+	e.header(snapMagic)
+	e.uvarint(uint64(t.size))
+
+	t.root.Walk(nil, func(key []byte, val *Item[V]) bool {
+		writeItem(e, key, val.list)
+		return e.err != nil
+	})
+
+	return e.close()
+
+}
+
+// ReadFrom replaces the contents of the tree with a snapshot read from
+// the reader, as written by WriteTo. The tree is only modified if the
+// whole snapshot is read successfully, and as a result, ReadFrom should
+// only be called on a tree which has not yet been shared. If the reader
+// does not implement io.ByteReader then it is buffered, in which case
+// data following the snapshot may be consumed. It returns the number
+// of bytes read.
+func (t *Tree[V]) ReadFrom(r io.Reader) (int64, error) {
+
+	d := newDecoder(r)
+
+	b := NewBuilder[V]()
+
+	if err := d.header(snapMagic); err != nil {
+		return d.n, err
+	}
+
+	num, err := d.uvarint()
+	if err != nil {
+		return d.n, err
+	}
+
+	for i := uint64(0); i < num; i++ {
+
+		key, val, err := readItem[V](d)
+		if err != nil {
+			return d.n, err
+		}
+
+		if err := b.Add(key, val); err != nil {
+			return d.n, ErrSnapshotFormat
+		}
+
+	}
+
+	if err := d.close(); err != nil {
+		return d.n, err
+	}
+
+	*t = *b.Tree()
+
+	return d.n, nil
+
+}
+
+// ---------------------------------------------------------------------------
+
+type encoder struct {
+	w   *bufio.Writer
+	h   hash.Hash32
+	n   int64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func newEncoder(w io.Writer) *encoder {
+	return &encoder{
+		w: bufio.NewWriter(w),
+		h: crc32.New(snapTable),
+	}
+}
+
+func (e *encoder) write(b []byte) {
+	if e.err == nil {
+		var n int
+		n, e.err = e.w.Write(b)
+		e.h.Write(b[:n])
+		e.n += int64(n)
+	}
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.write(e.buf[:binary.PutUvarint(e.buf[:], v)])
+}
+
+func (e *encoder) header(magic []byte) {
+	e.write(magic)
+	e.write([]byte{snapFormat})
+}
+
+func writeItem[V any](e *encoder, key []byte, list *elem[V]) {
+	e.uvarint(uint64(len(key)))
+	e.write(key)
+	e.uvarint(uint64(list.len()))
+	list.walk(func(v *elem[V]) bool {
+		e.uvarint(v.ver)
+		if v.dead {
+			e.write([]byte{kindDel})
+			return e.err != nil
+		}
+		val, ok := bytesOf(v.val)
+		switch {
+		case !ok:
+			e.fail(ErrValueType)
+		case val == nil:
+			e.write([]byte{kindNil})
+		default:
+			e.write([]byte{kindVal})
+			e.uvarint(uint64(len(val)))
+			e.write(val)
+		}
+		return e.err != nil
+	})
+}
+
+func (e *encoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *encoder) close() (int64, error) {
+	binary.BigEndian.PutUint32(e.buf[:4], e.h.Sum32())
+	e.write(e.buf[:4])
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.n, e.err
+}
+
+// readChunk is the most which is allocated at once for a length
+// which is read from the input, before the bytes have arrived.
+const readChunk = 64 << 10
+
+type decoder struct {
+	r io.ByteReader
+	o io.Reader
+	t io.Reader
+	h hash.Hash32
+	n int64
+	b [1]byte
+}
+
+func newDecoder(r io.Reader) *decoder {
+	d := &decoder{o: r, h: crc32.New(snapTable)}
+	if b, ok := r.(io.ByteReader); ok {
+		d.r = b
+	} else {
+		b := bufio.NewReader(r)
+		d.r, d.o = b, b
+	}
+	d.t = io.TeeReader(d.o, d.h)
+	return d
+}
+
+// ReadByte reads a single byte into the checksum, and is used only
+// for decoding varints.
+func (d *decoder) ReadByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.b[0] = b
+		d.h.Write(d.b[:])
+		d.n++
+	}
+	return b, err
+}
+
+// read reads the specified number of bytes. As the length is taken
+// from the input, the buffer is grown in chunks as the bytes arrive,
+// so that a corrupt length fails at the end of the input instead of
+// allocating.
+func (d *decoder) read(n uint64) ([]byte, error) {
+	b := make([]byte, 0, min(n, readChunk))
+	for uint64(len(b)) < n {
+		m := int(min(n-uint64(len(b)), readChunk))
+		b = slices.Grow(b, m)
+		k, err := io.ReadFull(d.t, b[len(b):len(b)+m])
+		b, d.n = b[:len(b)+k], d.n+int64(k)
+		if err != nil {
+			return nil, eof(err)
+		}
+	}
+	return b, nil
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(d)
+	if err != nil {
+		return 0, eof(err)
+	}
+	return v, nil
+}
+
+func (d *decoder) header(magic []byte) error {
+	b, err := d.read(uint64(len(magic)) + 1)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(b[:len(magic)], magic) {
+		return ErrSnapshotFormat
+	}
+	if b[len(magic)] != snapFormat {
+		return ErrSnapshotVersion
+	}
+	return nil
+}
+
+func readItem[V any](d *decoder) ([]byte, *Item[V], error) {
+
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := d.read(n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	num, err := d.uvarint()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The count is taken from the input, so
+	// the versions are not allocated up front
+	var es []*elem[V]
+
+	for i := uint64(0); i < num; i++ {
+
+		e := &elem[V]{}
+
+		if e.ver, err = d.uvarint(); err != nil {
+			return nil, nil, err
+		}
+
+		if i > 0 && e.ver <= es[i-1].ver {
+			return nil, nil, ErrSnapshotFormat
+		}
+
+		kind, err := d.ReadByte()
+		if err != nil {
+			return nil, nil, eof(err)
+		}
+
+		switch kind {
+		case kindVal:
+			if n, err = d.uvarint(); err != nil {
+				return nil, nil, err
+			}
+			val, err := d.read(n)
+			if err != nil {
+				return nil, nil, err
+			}
+			if e.val, err = value[V](val); err != nil {
+				return nil, nil, err
+			}
+		case kindDel:
+			e.dead = true
+		case kindNil:
+			if e.val, err = value[V](nil); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, ErrSnapshotFormat
+		}
+
+		es = append(es, e)
+
+	}
+
+	return key, &Item[V]{list: build(es)}, nil
+
+}
+
+func (d *decoder) close() error {
+	sum := d.h.Sum32()
+	b := make([]byte, 4)
+	for i := range b {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return eof(err)
+		}
+		b[i] = c
+		d.n++
+	}
+	if binary.BigEndian.Uint32(b) != sum {
+		return ErrSnapshotChecksum
+	}
+	return nil
+}
+
+// bytesOf returns the value as a byte slice, if it is one.
+func bytesOf[V any](v V) ([]byte, bool) {
+	b, ok := any(v).([]byte)
+	return b, ok
+}
+
+// valueOf returns the byte slice as a value of type V, if it is
+// possible to do so.
+func valueOf[V any](b []byte) (V, bool) {
+	v, ok := any(b).(V)
+	return v, ok
+}
+
+func value[V any](b []byte) (V, error) {
+	if v, ok := valueOf[V](b); ok {
+		return v, nil
+	}
+	var v V
+	return v, ErrValueType
+}
+
+func eof(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
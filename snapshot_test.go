@@ -0,0 +1,179 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func dump(t *Tree[[]byte]) (out []string) {
+	t.root.Walk(nil, func(key []byte, val *Item[[]byte]) bool {
+		val.Walk(func(ver uint64, v []byte, del bool) bool {
+			out = append(out, fmt.Sprintf("%s@%d=%q/%v/%v", key, ver, v, v == nil, del))
+			return false
+		})
+		return false
+	})
+	return
+}
+
+func TestEncoding(t *testing.T) {
+
+	c := New[[]byte]().Copy()
+	c.Put(0, []byte(""), []byte("ROOT"))
+	c.Put(1, []byte("/test"), []byte("ONE"))
+	c.Put(2, []byte("/test"), []byte("TWO"))
+	c.Del(3, []byte("/test"))
+	c.Put(4, []byte("/test"), nil)
+	c.Put(1, []byte("/test/nested"), []byte{})
+	for i := 0; i < 500; i++ {
+		c.Put(uint64(i%7), []byte(fmt.Sprintf("/key/%03d", i)), []byte(fmt.Sprint(i)))
+		c.Put(uint64(i%7+1), []byte(fmt.Sprintf("/key/%03d", i)), []byte(fmt.Sprint(-i)))
+	}
+	tree := c.Tree()
+
+	var buf bytes.Buffer
+
+	n, err := tree.WriteTo(&buf)
+
+	Convey("Can write a snapshot of a tree", t, func() {
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, buf.Len())
+		So(buf.Bytes()[:4], ShouldResemble, []byte("VTRE"))
+	})
+
+	Convey("Can read a snapshot into a tree", t, func() {
+		out := New[[]byte]()
+		m, err := out.ReadFrom(bytes.NewReader(buf.Bytes()))
+		So(err, ShouldBeNil)
+		So(m, ShouldEqual, n)
+		So(out.Size(), ShouldEqual, tree.Size())
+		So(dump(out), ShouldResemble, dump(tree))
+		So(out.Copy().Get(4, []byte("/test")), ShouldBeNil)
+		So(out.Copy().Get(3, []byte("/test")), ShouldBeNil)
+		So(out.Copy().Get(2, []byte("/test")), ShouldResemble, []byte("TWO"))
+		So(out.Copy().Get(0, []byte("")), ShouldResemble, []byte("ROOT"))
+		So(out.Copy().Get(1, []byte("/test/nested")), ShouldResemble, []byte{})
+	})
+
+	Convey("Can modify a tree read from a snapshot", t, func() {
+		out := New[[]byte]()
+		_, err := out.ReadFrom(bytes.NewReader(buf.Bytes()))
+		So(err, ShouldBeNil)
+		c := out.Copy()
+		So(c.Put(5, []byte("/test"), []byte("FIVE")), ShouldBeNil)
+		So(c.Put(3, []byte("/key/001"), []byte("NEW")), ShouldResemble, []byte("-1"))
+		So(c.Get(5, []byte("/test")), ShouldResemble, []byte("FIVE"))
+		So(c.Get(3, []byte("/key/001")), ShouldResemble, []byte("NEW"))
+		So(c.Get(2, []byte("/key/001")), ShouldResemble, []byte("-1"))
+		So(c.Get(1, []byte("/key/001")), ShouldResemble, []byte("1"))
+		So(dump(out), ShouldResemble, dump(tree))
+	})
+
+	Convey("Can read a snapshot from an unbuffered reader", t, func() {
+		out := New[[]byte]()
+		m, err := out.ReadFrom(io.MultiReader(bytes.NewReader(buf.Bytes())))
+		So(err, ShouldBeNil)
+		So(m, ShouldEqual, n)
+		So(dump(out), ShouldResemble, dump(tree))
+	})
+
+	Convey("Can write and read an empty tree", t, func() {
+		var buf bytes.Buffer
+		_, err := New[[]byte]().WriteTo(&buf)
+		So(err, ShouldBeNil)
+		out := New[[]byte]()
+		_, err = out.ReadFrom(&buf)
+		So(err, ShouldBeNil)
+		So(out.Size(), ShouldEqual, 0)
+	})
+
+	Convey("Can not read a snapshot with invalid magic", t, func() {
+		out := tree
+		bad := append([]byte("XXXX"), buf.Bytes()[4:]...)
+		_, err := out.ReadFrom(bytes.NewReader(bad))
+		So(err, ShouldEqual, ErrSnapshotFormat)
+		So(dump(out), ShouldResemble, dump(tree))
+	})
+
+	Convey("Can not read a snapshot with unknown format", t, func() {
+		bad := append([]byte{}, buf.Bytes()...)
+		bad[4] = 99
+		_, err := New[[]byte]().ReadFrom(bytes.NewReader(bad))
+		So(err, ShouldEqual, ErrSnapshotVersion)
+	})
+
+	Convey("Can not read a snapshot with corrupted data", t, func() {
+		bad := append([]byte{}, buf.Bytes()...)
+		bad[len(bad)/2] ^= 0x01
+		out := New[[]byte]()
+		_, err := out.ReadFrom(bytes.NewReader(bad))
+		So(err, ShouldNotBeNil)
+		So(out.Size(), ShouldEqual, 0)
+	})
+
+	Convey("Can not read a snapshot with corrupted checksum", t, func() {
+		bad := append([]byte{}, buf.Bytes()...)
+		bad[len(bad)-1] ^= 0x01
+		_, err := New[[]byte]().ReadFrom(bytes.NewReader(bad))
+		So(err, ShouldEqual, ErrSnapshotChecksum)
+	})
+
+	Convey("Can not read a snapshot with corrupted lengths", t, func() {
+		head := appendUvarint(append([]byte("VTRE"), snapFormat), 1)
+		keys := appendUvarint(append([]byte{}, head...), 1<<62)
+		vers := appendUvarint(appendUvarint(append(append([]byte{}, head...), 1), '/'), 1<<61)
+		for _, bad := range [][]byte{keys, vers} {
+			out := New[[]byte]()
+			_, err := out.ReadFrom(bytes.NewReader(bad))
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+			So(out.Size(), ShouldEqual, 0)
+		}
+	})
+
+	Convey("Can not read a truncated snapshot", t, func() {
+		for _, i := range []int{0, 3, 5, buf.Len() / 3, buf.Len() - 1} {
+			_, err := New[[]byte]().ReadFrom(bytes.NewReader(buf.Bytes()[:i]))
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+		}
+	})
+
+}
+
+func BenchmarkReadFrom(b *testing.B) {
+	c := New[[]byte]().Copy()
+	for i := 0; i < 10000; i++ {
+		k := []byte(fmt.Sprintf("/test/%d/%d", i%100, i))
+		c.Put(1, k, bytes.Repeat(k, 4))
+		c.Put(2, k, k)
+	}
+	var buf bytes.Buffer
+	if _, err := c.Tree().WriteTo(&buf); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(buf.Len()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := New[[]byte]().ReadFrom(bytes.NewReader(buf.Bytes())); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Store holds the most recently committed tree, and is safe for
+// concurrent use. Readers load the current tree without locking,
+// while writers are serialized, with each change being published
+// atomically once it has been applied successfully.
+type Store[V any] struct {
+	lock    sync.Mutex
+	tree    atomic.Value
+	subs    map[*Subscription[V]]struct{}
+	watches map[*watch[V]]struct{}
+}
+
+// NewStore returns a store holding the specified tree. If the tree
+// is nil, then the store is initialised with an empty tree.
+func NewStore[V any](t *Tree[V]) *Store[V] {
+	if t == nil {
+		t = New[V]()
+	}
+	s := &Store[V]{}
+	s.tree.Store(&Tree[V]{size: t.size, root: t.root, clock: t.clock, gone: t.gone, store: s})
+	return s
+}
+
+// Load returns the most recently committed tree. It never blocks,
+// and the returned tree is unaffected by any subsequent updates.
+func (s *Store[V]) Load() *Tree[V] {
+	return s.tree.Load().(*Tree[V])
+}
+
+// Update applies changes to a copy of the current tree. Updates are
+// serialized, so that no changes are lost. If the function returns an
+// error, or panics, then the changes are discarded, otherwise the new
+// tree is published atomically to all subsequent readers, and the
+// changes are delivered to any subscriptions.
+func (s *Store[V]) Update(fn func(*Copy[V]) error) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c := s.Load().Copy()
+
+	c.track = len(s.subs) > 0
+
+	if err := fn(c); err != nil {
+		return err
+	}
+
+	t := c.Tree()
+
+	t.store = s
+
+	s.tree.Store(t)
+
+	s.notify(t)
+
+	if len(c.feed) > 0 {
+		s.publish(&Changeset[V]{Tree: t, Changes: c.feed})
+	}
+
+	return nil
+
+}
+
+// View calls the function with the current tree, providing a
+// consistent read scope which is unaffected by concurrent updates.
+func (s *Store[V]) View(fn func(*Tree[V]) error) error {
+	return fn(s.Load())
+}
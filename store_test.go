@@ -0,0 +1,147 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStore(t *testing.T) {
+
+	Convey("Can create an empty store", t, func() {
+		s := NewStore[[]byte](nil)
+		So(s.Load(), ShouldNotBeNil)
+		So(s.Load().Size(), ShouldEqual, 0)
+	})
+
+	Convey("Can update a store", t, func() {
+		s := NewStore(New[[]byte]())
+		old := s.Load()
+		err := s.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/test"), []byte("ONE"))
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(old.Size(), ShouldEqual, 0)
+		So(s.Load().Size(), ShouldEqual, 1)
+		So(s.View(func(t *Tree[[]byte]) error {
+			So(t.At(1).Get([]byte("/test")), ShouldResemble, []byte("ONE"))
+			return nil
+		}), ShouldBeNil)
+	})
+
+	Convey("Can rollback an update on error", t, func() {
+		s := NewStore[[]byte](nil)
+		old := s.Load()
+		err := s.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/test"), []byte("ONE"))
+			return errors.New("failed")
+		})
+		So(err, ShouldNotBeNil)
+		So(s.Load(), ShouldEqual, old)
+	})
+
+	Convey("Can rollback an update on panic", t, func() {
+		s := NewStore[[]byte](nil)
+		old := s.Load()
+		So(func() {
+			s.Update(func(c *Copy[[]byte]) error {
+				c.Put(1, []byte("/test"), []byte("ONE"))
+				panic("failed")
+			})
+		}, ShouldPanic)
+		So(s.Load(), ShouldEqual, old)
+		So(s.Update(func(c *Copy[[]byte]) error {
+			return nil
+		}), ShouldBeNil)
+	})
+
+	Convey("Can update and read concurrently", t, func() {
+
+		const writers, readers, updates = 8, 8, 200
+
+		s := NewStore[[]byte](nil)
+
+		var wg sync.WaitGroup
+		var rg sync.WaitGroup
+		var bad sync.Map
+
+		done := make(chan struct{})
+
+		for i := 0; i < readers; i++ {
+			rg.Add(1)
+			go func() {
+				defer rg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					s.View(func(t *Tree[[]byte]) error {
+						v := t.At(1)
+						n, _ := strconv.Atoi(string(v.Get([]byte("/count"))))
+						k := 0
+						v.Walk([]byte("/item/"), func(key, val []byte) (e bool) {
+							k++
+							return
+						})
+						if k != n || t.Size() != n+1 && n > 0 {
+							bad.Store(n, k)
+						}
+						return nil
+					})
+				}
+			}()
+		}
+
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < updates; j++ {
+					s.Update(func(c *Copy[[]byte]) error {
+						n, _ := strconv.Atoi(string(c.Get(1, []byte("/count"))))
+						c.Put(1, []byte(fmt.Sprintf("/item/%d/%d", i, j)), []byte("OK"))
+						c.Put(1, []byte("/count"), []byte(strconv.Itoa(n+1)))
+						return nil
+					})
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		close(done)
+		rg.Wait()
+
+		n := 0
+		bad.Range(func(k, v interface{}) bool {
+			n++
+			return true
+		})
+
+		So(n, ShouldEqual, 0)
+		So(s.Load().Size(), ShouldEqual, writers*updates+1)
+		So(s.Load().At(1).Get([]byte("/count")), ShouldResemble, []byte(strconv.Itoa(writers*updates)))
+
+	})
+
+}
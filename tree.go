@@ -14,29 +14,100 @@
 
 package vtree
 
-// Tree represents an immutable versioned radix tree.
-type Tree struct {
-	size int
-	root *Node
+import (
+	"iter"
+)
+
+// Tree represents an immutable versioned radix tree, holding values
+// of type V. Values are stored as they are, so that structs, pointers
+// or handles can be stored without being encoded. Only trees of []byte
+// values can be written to snapshots, deltas, batches or a DB.
+type Tree[V any] struct {
+	size  int
+	root  *Node[V]
+	clock uint64
+	gone  *removal
+	store *Store[V]
 }
 
-// New returns an empty Tree
-func New() *Tree {
-	return &Tree{root: &Node{}}
+// New returns an empty Tree, holding values of type V.
+func New[V any]() *Tree[V] {
+	return &Tree[V]{root: &Node[V]{}}
 }
 
 // Size is used to return the number of elements in the tree.
-func (t *Tree) Size() int {
+func (t *Tree[V]) Size() int {
 	return t.size
 }
 
 // Copy starts a new transaction that can be used to mutate the tree
-func (t *Tree) Copy() *Copy {
-	return &Copy{size: t.size, root: t.root}
+func (t *Tree[V]) Copy() *Copy[V] {
+	c := &Copy[V]{size: t.size, root: t.root, clock: t.clock, gone: t.gone}
+	c.seal()
+	return c
+}
+
+// At returns a read-only view of the tree at the specified version.
+func (t *Tree[V]) At(ver uint64) *View[V] {
+	return &View[V]{ver: ver, tree: t.Copy()}
+}
+
+// All returns an iterator over every key and item in the tree, in
+// ascending key order.
+func (t *Tree[V]) All() iter.Seq2[[]byte, *Item[V]] {
+	return t.Prefix(nil)
+}
+
+// Prefix returns an iterator over every key and item in the tree which
+// begins with the specified prefix, in ascending key order.
+func (t *Tree[V]) Prefix(prefix []byte) iter.Seq2[[]byte, *Item[V]] {
+	return func(yield func([]byte, *Item[V]) bool) {
+		t.root.Walk(prefix, func(key []byte, val *Item[V]) bool {
+			return !yield(key, val)
+		})
+	}
+}
+
+// Range returns an iterator over every key and item in the tree which
+// is greater than or equal to the start key, and less than the end
+// key, in ascending key order. A nil start or end key is unbounded.
+func (t *Tree[V]) Range(start, end []byte) iter.Seq2[[]byte, *Item[V]] {
+	return func(yield func([]byte, *Item[V]) bool) {
+		t.Copy().Cursor().Range(Bounds{Start: start, End: end}, func(key []byte, val *Item[V]) bool {
+			return !yield(key, val)
+		})
+	}
+}
+
+// Backward returns an iterator over every key and item in the tree, in
+// descending key order.
+func (t *Tree[V]) Backward() iter.Seq2[[]byte, *Item[V]] {
+	return func(yield func([]byte, *Item[V]) bool) {
+		c := t.Copy().Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
 }
 
 // Walker represents a callback function which is to be used when
 // iterating through the tree using Path, Subs, or Walk. It will be
 // populated with the key and list of the current item, and returns
-// a bool signifying if the iteration should be terminated.
-type Walker func(key []byte, val *Item) (exit bool)
+// a bool signifying if the iteration should be terminated. The
+// iterators returned by All, Prefix, Range and Backward can be used
+// with a range loop instead.
+type Walker[V any] func(key []byte, val *Item[V]) (exit bool)
+
+// Live returns a Walker which passes items on to the specified
+// Walker, skipping any item where the version visible at the
+// specified version number is a tombstone.
+func Live[V any](ver uint64, f Walker[V]) Walker[V] {
+	return func(key []byte, val *Item[V]) (exit bool) {
+		if val.Deleted(ver) {
+			return false
+		}
+		return f(key, val)
+	}
+}
@@ -15,7 +15,13 @@
 package vtree
 
 import (
+	"bytes"
 	"fmt"
+	"iter"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -99,7 +105,7 @@ var p = [][]int{
 
 func TestBasic(t *testing.T) {
 
-	p := New()
+	p := New[[]byte]()
 
 	c := p.Copy()
 
@@ -157,7 +163,7 @@ func TestBasic(t *testing.T) {
 
 func TestComplex(t *testing.T) {
 
-	p := New()
+	p := New[[]byte]()
 	c := p.Copy()
 
 	Convey("Can get empty `min`", t, func() {
@@ -201,7 +207,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `nil` with `walk`", t, func() {
 		i := 0
-		c.Root().Walk(nil, func(k []byte, v *Item) (e bool) {
+		c.Root().Walk(nil, func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -210,7 +216,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/s` with `walk`", t, func() {
 		i := 0
-		c.Root().Walk([]byte("/test/zen/s"), func(k []byte, v *Item) (e bool) {
+		c.Root().Walk([]byte("/test/zen/s"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -219,7 +225,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub` with `walk`", t, func() {
 		i := 0
-		c.Root().Walk([]byte("/test/zen/sub"), func(k []byte, v *Item) (e bool) {
+		c.Root().Walk([]byte("/test/zen/sub"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -228,7 +234,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub-o` with `walk`", t, func() {
 		i := 0
-		c.Root().Walk([]byte("/test/zen/sub-o"), func(k []byte, v *Item) (e bool) {
+		c.Root().Walk([]byte("/test/zen/sub-o"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -237,7 +243,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub-one` with `walk`", t, func() {
 		i := 0
-		c.Root().Walk([]byte("/test/zen/sub-one"), func(k []byte, v *Item) (e bool) {
+		c.Root().Walk([]byte("/test/zen/sub-one"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -246,7 +252,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub` with `walk` and exit", t, func() {
 		i := 0
-		c.Root().Walk([]byte("/test/zen/sub"), func(k []byte, v *Item) (e bool) {
+		c.Root().Walk([]byte("/test/zen/sub"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return true
 		})
@@ -257,7 +263,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/` with `subs`", t, func() {
 		i := 0
-		c.Root().Subs([]byte("/test/"), func(k []byte, v *Item) (e bool) {
+		c.Root().Subs([]byte("/test/"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -266,7 +272,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/s` with `subs`", t, func() {
 		i := 0
-		c.Root().Subs([]byte("/test/zen/s"), func(k []byte, v *Item) (e bool) {
+		c.Root().Subs([]byte("/test/zen/s"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -275,7 +281,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub` with `subs`", t, func() {
 		i := 0
-		c.Root().Subs([]byte("/test/zen/sub"), func(k []byte, v *Item) (e bool) {
+		c.Root().Subs([]byte("/test/zen/sub"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -284,7 +290,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub-o` with `subs`", t, func() {
 		i := 0
-		c.Root().Subs([]byte("/test/zen/sub-t"), func(k []byte, v *Item) (e bool) {
+		c.Root().Subs([]byte("/test/zen/sub-t"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -293,7 +299,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub-one` with `subs`", t, func() {
 		i := 0
-		c.Root().Subs([]byte("/test/zen/sub-one"), func(k []byte, v *Item) (e bool) {
+		c.Root().Subs([]byte("/test/zen/sub-one"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -302,7 +308,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub` with `subs` and exit", t, func() {
 		i := 0
-		c.Root().Subs([]byte("/test/zen/sub"), func(k []byte, v *Item) (e bool) {
+		c.Root().Subs([]byte("/test/zen/sub"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return true
 		})
@@ -313,7 +319,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `nil` with `path`", t, func() {
 		i := 0
-		c.Root().Path(nil, func(k []byte, v *Item) (e bool) {
+		c.Root().Path(nil, func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -322,7 +328,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/s` with `path`", t, func() {
 		i := 0
-		c.Root().Path([]byte("/test/zen/s"), func(k []byte, v *Item) (e bool) {
+		c.Root().Path([]byte("/test/zen/s"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -331,7 +337,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub` with `path`", t, func() {
 		i := 0
-		c.Root().Path([]byte("/test/zen/sub"), func(k []byte, v *Item) (e bool) {
+		c.Root().Path([]byte("/test/zen/sub"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -340,7 +346,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub-o` with `path`", t, func() {
 		i := 0
-		c.Root().Path([]byte("/test/zen/sub-o"), func(k []byte, v *Item) (e bool) {
+		c.Root().Path([]byte("/test/zen/sub-o"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -349,7 +355,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub-one` with `path`", t, func() {
 		i := 0
-		c.Root().Path([]byte("/test/zen/sub-one"), func(k []byte, v *Item) (e bool) {
+		c.Root().Path([]byte("/test/zen/sub-one"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return
 		})
@@ -358,7 +364,7 @@ func TestComplex(t *testing.T) {
 
 	Convey("Can iterate tree items at `/test/zen/sub` with `path` and exit", t, func() {
 		i := 0
-		c.Root().Path([]byte("/test/zen/sub"), func(k []byte, v *Item) (e bool) {
+		c.Root().Path([]byte("/test/zen/sub"), func(k []byte, v *Item[[]byte]) (e bool) {
 			i++
 			return true
 		})
@@ -369,7 +375,7 @@ func TestComplex(t *testing.T) {
 
 func TestIritate(t *testing.T) {
 
-	c := New().Copy()
+	c := New[[]byte]().Copy()
 
 	i := c.Cursor()
 
@@ -401,7 +407,7 @@ func TestIritate(t *testing.T) {
 
 func TestIterate(t *testing.T) {
 
-	c := New().Copy()
+	c := New[[]byte]().Copy()
 
 	Convey("Can insert tree items", t, func() {
 		for _, v := range s {
@@ -635,9 +641,678 @@ func TestIterate(t *testing.T) {
 
 }
 
+func TestSeek(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+
+	var probes []string
+	var gen func(p string)
+	gen = func(p string) {
+		probes = append(probes, p)
+		if len(p) < 4 {
+			for _, b := range "-/ab" {
+				gen(p + string(b))
+			}
+		}
+	}
+	gen("")
+
+	trees := [][]string{s}
+	for i := 0; i < 20; i++ {
+		var keys []string
+		for j := r.Intn(40); j >= 0; j-- {
+			k := make([]byte, 1+r.Intn(5))
+			for x := range k {
+				k[x] = "/ab"[r.Intn(3)]
+			}
+			keys = append(keys, string(k))
+		}
+		trees = append(trees, keys)
+	}
+
+	Convey("Can seek against a sorted reference", t, func() {
+		for _, keys := range trees {
+			c := New[[]byte]().Copy()
+			for _, k := range keys {
+				c.Put(0, []byte(k), []byte(k))
+			}
+			ref := []string{}
+			c.Root().Walk(nil, func(k []byte, v *Item[[]byte]) (e bool) {
+				ref = append(ref, string(k))
+				return
+			})
+			So(sort.StringsAreSorted(ref), ShouldBeTrue)
+			all := append(append([]string{}, probes...), ref...)
+			for _, k := range ref {
+				all = append(all, k+"-", k+"/", k[:len(k)-1]+"0")
+			}
+			for _, p := range all {
+				lo := sort.SearchStrings(ref, p)
+				hi := sort.Search(len(ref), func(i int) bool { return ref[i] > p })
+				i := c.Cursor()
+				k, _ := i.Seek([]byte(p))
+				So(k, ShouldResemble, at(ref, lo))
+				if k != nil {
+					k, _ = i.Prev()
+					So(k, ShouldResemble, at(ref, lo-1))
+					i.Seek([]byte(p))
+					k, _ = i.Next()
+					So(k, ShouldResemble, at(ref, lo+1))
+				}
+				k, _ = i.SeekLE([]byte(p))
+				So(k, ShouldResemble, at(ref, hi-1))
+				if k != nil {
+					k, _ = i.Next()
+					So(k, ShouldResemble, at(ref, hi))
+					i.SeekLE([]byte(p))
+					k, _ = i.Prev()
+					So(k, ShouldResemble, at(ref, hi-2))
+				}
+				k, _ = i.SeekLT([]byte(p))
+				So(k, ShouldResemble, at(ref, lo-1))
+				if k != nil {
+					k, _ = i.Next()
+					So(k, ShouldResemble, at(ref, lo))
+				}
+			}
+		}
+	})
+
+	Convey("Can seek in reverse skipping deleted items", t, func() {
+		c := New[[]byte]().Copy()
+		for _, v := range s {
+			c.Put(1, []byte(v), []byte(v))
+		}
+		c.Del(2, []byte(s[9]))
+		c.Del(2, []byte(s[10]))
+		i := c.Cursor().Live(2)
+		k, _ := i.SeekLE([]byte(s[10]))
+		So(k, ShouldResemble, []byte(s[8]))
+		k, _ = i.SeekLT([]byte(s[11]))
+		So(k, ShouldResemble, []byte(s[8]))
+		k, _ = i.Next()
+		So(k, ShouldResemble, []byte(s[11]))
+	})
+
+}
+
+func TestPrefixCursor(t *testing.T) {
+
+	c := New[[]byte]().Copy()
+
+	for _, v := range s {
+		c.Put(0, []byte(v), []byte(v))
+	}
+
+	prefixes := []string{
+		"", "/", "/s", "/test", "/test/", "/test/o", "/test/one", "/test/one/",
+		"/test/one/sub-one/1st", "/test/one/sub-one/1st/", "/test/x", "/x", "/zoo/some/path", "a",
+	}
+
+	Convey("Can iterate within a prefix", t, func() {
+		for _, pre := range prefixes {
+			var ref []string
+			for _, k := range s {
+				if strings.HasPrefix(k, pre) {
+					ref = append(ref, k)
+				}
+			}
+			var fwd, bwd []string
+			i := c.PrefixCursor([]byte(pre))
+			for k, _ := i.First(); k != nil; k, _ = i.Next() {
+				fwd = append(fwd, string(k))
+			}
+			for k, _ := i.Last(); k != nil; k, _ = i.Prev() {
+				bwd = append([]string{string(k)}, bwd...)
+			}
+			So(fwd, ShouldResemble, ref)
+			So(bwd, ShouldResemble, ref)
+			for _, p := range append([]string{"", "\xff"}, s...) {
+				for _, p := range []string{p, p + "-", p[:len(p)/2]} {
+					lo := sort.SearchStrings(ref, p)
+					hi := sort.Search(len(ref), func(i int) bool { return ref[i] > p })
+					k, _ := i.Seek([]byte(p))
+					So(k, ShouldResemble, at(ref, lo))
+					k, _ = i.SeekLE([]byte(p))
+					So(k, ShouldResemble, at(ref, hi-1))
+					k, _ = i.SeekLT([]byte(p))
+					So(k, ShouldResemble, at(ref, lo-1))
+				}
+			}
+		}
+	})
+
+	Convey("Can iterate a range within a prefix", t, func() {
+		var out []string
+		c.PrefixCursor([]byte("/test/two/")).Range(Bounds{Reverse: true, Limit: 4}, func(k []byte, v *Item[[]byte]) (e bool) {
+			out = append(out, string(k))
+			return
+		})
+		So(out, ShouldResemble, []string{s[21], s[20], s[19], s[18]})
+	})
+
+}
+
+func TestRange(t *testing.T) {
+
+	c := New[[]byte]().Copy()
+
+	for _, v := range s {
+		c.Put(0, []byte(v), []byte(v))
+	}
+
+	probes := [][]byte{nil, {0}, {255}}
+	for _, v := range s {
+		probes = append(probes, []byte(v), []byte(v[:len(v)-1]), []byte(v+"-"), []byte(v+"/"))
+	}
+
+	expect := func(b Bounds) (out []string) {
+		for _, k := range s {
+			if b.Start != nil {
+				if x := bytes.Compare([]byte(k), b.Start); x < 0 || x == 0 && b.StartExclusive {
+					continue
+				}
+			}
+			if b.End != nil {
+				if x := bytes.Compare([]byte(k), b.End); x > 0 || x == 0 && !b.EndInclusive {
+					continue
+				}
+			}
+			if b.Reverse {
+				out = append([]string{k}, out...)
+			} else {
+				out = append(out, k)
+			}
+		}
+		if b.Limit > 0 && len(out) > b.Limit {
+			out = out[:b.Limit]
+		}
+		return
+	}
+
+	Convey("Can iterate over bounded ranges", t, func() {
+		i := c.Cursor()
+		for _, beg := range probes {
+			for _, end := range probes {
+				for f := 0; f < 16; f++ {
+					b := Bounds{
+						Start:          beg,
+						End:            end,
+						StartExclusive: f&1 != 0,
+						EndInclusive:   f&2 != 0,
+						Reverse:        f&4 != 0,
+					}
+					if f&8 != 0 {
+						b.Limit = 3
+					}
+					var out []string
+					i.Range(b, func(k []byte, v *Item[[]byte]) (e bool) {
+						out = append(out, string(k))
+						return
+					})
+					So(out, ShouldResemble, expect(b))
+				}
+			}
+		}
+	})
+
+	Convey("Can iterate over a range and exit", t, func() {
+		n := 0
+		c.Cursor().Range(Bounds{Reverse: true}, func(k []byte, v *Item[[]byte]) (e bool) {
+			n++
+			return true
+		})
+		So(n, ShouldEqual, 1)
+	})
+
+}
+
+func TestIterators(t *testing.T) {
+
+	c := New[[]byte]().Copy()
+
+	for _, v := range s {
+		c.Put(1, []byte(v), []byte(v))
+	}
+
+	tr := c.Tree()
+
+	keys := func(seq iter.Seq2[[]byte, *Item[[]byte]]) (out []string) {
+		for k, v := range seq {
+			So(v.Get(1), ShouldResemble, k)
+			out = append(out, string(k))
+		}
+		return
+	}
+
+	Convey("Can iterate over all keys", t, func() {
+		So(keys(tr.All()), ShouldResemble, s)
+	})
+
+	Convey("Can iterate over all keys backward", t, func() {
+		out := keys(tr.Backward())
+		So(out, ShouldHaveLength, len(s))
+		for i := range out {
+			So(out[i], ShouldEqual, s[len(s)-1-i])
+		}
+	})
+
+	Convey("Can iterate over keys with a prefix", t, func() {
+		So(keys(tr.Prefix([]byte("/test/two/"))), ShouldResemble, s[13:22])
+		So(keys(tr.Prefix([]byte("/none"))), ShouldBeEmpty)
+	})
+
+	Convey("Can iterate over a range of keys", t, func() {
+		So(keys(tr.Range([]byte("/test/one/sub-one/1st"), []byte("/test/one/sub-two"))), ShouldResemble, s[4:6])
+		So(keys(tr.Range(nil, []byte("/test"))), ShouldResemble, s[:1])
+		So(keys(tr.Range([]byte("/zzz"), nil)), ShouldBeEmpty)
+	})
+
+	Convey("Can stop iterating early", t, func() {
+		for _, seq := range []iter.Seq2[[]byte, *Item[[]byte]]{tr.All(), tr.Backward(), tr.Prefix(nil), tr.Range(nil, nil)} {
+			n := 0
+			for range seq {
+				n++
+				if n == 3 {
+					break
+				}
+			}
+			So(n, ShouldEqual, 3)
+		}
+	})
+
+	Convey("Can iterate over the versions of an item", t, func() {
+		i, _ := newItem[[]byte]().put(1, []byte("ONE")).put(2, []byte("TWO")).del(3)
+		i = i.put(4, []byte("FOUR"))
+		var vers []uint64
+		var vals []string
+		for ver, val := range i.Versions() {
+			vers, vals = append(vers, ver), append(vals, string(val))
+		}
+		So(vers, ShouldResemble, []uint64{1, 2, 4})
+		So(vals, ShouldResemble, []string{"ONE", "TWO", "FOUR"})
+		vers = nil
+		for ver := range i.VersionsBackward() {
+			vers = append(vers, ver)
+			if ver == 2 {
+				break
+			}
+		}
+		So(vers, ShouldResemble, []uint64{4, 2})
+	})
+
+}
+
+func TestItem(t *testing.T) {
+
+	i := newItem[[]byte]()
+	for _, v := range []uint64{2, 4, 6, 8, 10} {
+		i = i.put(v, []byte(fmt.Sprint(v)))
+	}
+	i, _ = i.del(7)
+
+	walk := func(fn func(func(uint64, []byte, bool) bool)) (vers []uint64, dels []bool) {
+		fn(func(ver uint64, val []byte, del bool) bool {
+			vers, dels = append(vers, ver), append(dels, del)
+			return false
+		})
+		return
+	}
+
+	Convey("Can count the versions of an item", t, func() {
+		So(i.Len(), ShouldEqual, 6)
+		So(newItem[[]byte]().Len(), ShouldEqual, 0)
+	})
+
+	Convey("Can walk over the versions in reverse", t, func() {
+		vers, dels := walk(i.WalkReverse)
+		So(vers, ShouldResemble, []uint64{10, 8, 7, 6, 4, 2})
+		So(dels, ShouldResemble, []bool{false, false, true, false, false, false})
+		n := 0
+		i.WalkReverse(func(ver uint64, val []byte, del bool) bool {
+			n++
+			return ver == 8
+		})
+		So(n, ShouldEqual, 2)
+	})
+
+	Convey("Can walk over a range of versions", t, func() {
+		rng := func(from, to uint64) []uint64 {
+			vers, _ := walk(func(fn func(uint64, []byte, bool) bool) {
+				i.Range(from, to, fn)
+			})
+			return vers
+		}
+		So(rng(4, 8), ShouldResemble, []uint64{4, 6, 7, 8})
+		So(rng(3, 5), ShouldResemble, []uint64{4})
+		So(rng(0, 100), ShouldResemble, []uint64{2, 4, 6, 7, 8, 10})
+		So(rng(11, 20), ShouldBeNil)
+		So(rng(8, 4), ShouldBeNil)
+	})
+
+	Convey("Can select the versions since a version", t, func() {
+		j := i.Since(6)
+		vers, _ := walk(j.Walk)
+		So(vers, ShouldResemble, []uint64{7, 8, 10})
+		So(j.Deleted(7), ShouldBeTrue)
+		So(i.Len(), ShouldEqual, 6)
+		So(i.Since(10).Len(), ShouldEqual, 0)
+		So(i.Since(0).Len(), ShouldEqual, 6)
+	})
+
+	Convey("Can step forward through the versions", t, func() {
+		var vers []uint64
+		var dels []bool
+		for v, _, del, ok := i.Next(0); ok; v, _, del, ok = i.Next(v) {
+			vers, dels = append(vers, v), append(dels, del)
+		}
+		So(vers, ShouldResemble, []uint64{2, 4, 6, 7, 8, 10})
+		So(dels, ShouldResemble, []bool{false, false, false, true, false, false})
+		v, val, del, ok := i.Next(4)
+		So(v, ShouldEqual, 6)
+		So(val, ShouldResemble, []byte("6"))
+		So(del, ShouldBeFalse)
+		So(ok, ShouldBeTrue)
+		v, val, del, ok = i.Next(6)
+		So(v, ShouldEqual, 7)
+		So(val, ShouldBeNil)
+		So(del, ShouldBeTrue)
+		So(ok, ShouldBeTrue)
+	})
+
+	Convey("Can step backward through the versions", t, func() {
+		var vers []uint64
+		var dels []bool
+		for v, _, del, ok := i.Prev(math.MaxUint64); ok; v, _, del, ok = i.Prev(v) {
+			vers, dels = append(vers, v), append(dels, del)
+		}
+		So(vers, ShouldResemble, []uint64{10, 8, 7, 6, 4, 2})
+		So(dels, ShouldResemble, []bool{false, false, true, false, false, false})
+		v, val, del, ok := i.Prev(8)
+		So(v, ShouldEqual, 7)
+		So(val, ShouldBeNil)
+		So(del, ShouldBeTrue)
+		So(ok, ShouldBeTrue)
+		v, _, del, ok = i.Prev(7)
+		So(v, ShouldEqual, 6)
+		So(del, ShouldBeFalse)
+		So(ok, ShouldBeTrue)
+		_, _, _, ok = i.Prev(2)
+		So(ok, ShouldBeFalse)
+	})
+
+}
+
+func sized(n *Node[[]byte]) bool {
+	num := 0
+	if n.isLeaf() {
+		num++
+	}
+	for _, e := range n.edges {
+		if !sized(e) {
+			return false
+		}
+		num += e.size
+	}
+	return n.size == num
+}
+
+func TestStatistics(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+
+	Convey("Can count, rank, and select against a sorted reference", t, func() {
+		c := New[[]byte]().Copy()
+		for i := 0; i < 40; i++ {
+			for j := 0; j < 20; j++ {
+				k := make([]byte, r.Intn(6))
+				for x := range k {
+					k[x] = "/ab"[r.Intn(3)]
+				}
+				switch r.Intn(5) {
+				case 0:
+					c.Cut(k)
+				case 1:
+					c.Del(uint64(i), k)
+				default:
+					c.Put(uint64(i), k, k)
+				}
+			}
+			if i%10 == 9 {
+				c.Compact(uint64(i))
+			}
+			ref := []string{}
+			c.Root().Walk(nil, func(k []byte, v *Item[[]byte]) (e bool) {
+				ref = append(ref, string(k))
+				return
+			})
+			So(sized(c.Root()), ShouldBeTrue)
+			So(c.Root().size, ShouldEqual, c.Size())
+			So(c.Root().size, ShouldEqual, len(ref))
+			for _, k := range ref {
+				for _, p := range []string{k, k + "/", k + "0", k[:len(k)/2]} {
+					num := 0
+					for _, x := range ref {
+						if strings.HasPrefix(x, p) {
+							num++
+						}
+					}
+					So(c.CountPrefix([]byte(p)), ShouldEqual, num)
+					So(c.Rank([]byte(p)), ShouldEqual, sort.SearchStrings(ref, p))
+				}
+			}
+			for x := -1; x <= len(ref); x++ {
+				k, _ := c.Select(x)
+				So(k, ShouldResemble, at(ref, x))
+			}
+		}
+	})
+
+	Convey("Can seek to an index and continue iterating", t, func() {
+		c := New[[]byte]().Copy()
+		for _, v := range s {
+			c.Put(0, []byte(v), []byte(v))
+		}
+		So(c.CountPrefix([]byte("/test/")), ShouldEqual, 30)
+		So(c.CountPrefix([]byte("/test/o")), ShouldEqual, 10)
+		So(c.CountPrefix([]byte("/x")), ShouldEqual, 0)
+		So(c.Rank([]byte(s[12])), ShouldEqual, 12)
+		i := c.Cursor()
+		k, _ := i.SeekIndex(12)
+		So(k, ShouldResemble, []byte(s[12]))
+		k, _ = i.Next()
+		So(k, ShouldResemble, []byte(s[13]))
+		i.SeekIndex(12)
+		k, _ = i.Prev()
+		So(k, ShouldResemble, []byte(s[11]))
+		p := c.PrefixCursor([]byte("/test/two/"))
+		k, _ = p.SeekIndex(0)
+		So(k, ShouldResemble, []byte(s[13]))
+		k, _ = p.SeekIndex(8)
+		So(k, ShouldResemble, []byte(s[21]))
+		k, _ = p.SeekIndex(9)
+		So(k, ShouldBeNil)
+	})
+
+}
+
+func TestCutRange(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+
+	probes := []string{"", "/", "/a", "/a/", "/ab", "/b", "/ba", "a", "ab", "b", "b/", "bb", "\xff"}
+
+	var trees [][]string
+	for i := 0; i < 20; i++ {
+		var keys []string
+		for j := r.Intn(40); j >= 0; j-- {
+			k := make([]byte, r.Intn(5))
+			for x := range k {
+				k[x] = "/ab"[r.Intn(3)]
+			}
+			keys = append(keys, string(k))
+		}
+		trees = append(trees, keys)
+	}
+
+	build := func(keys []string) (*Tree[[]byte], []string) {
+		c := New[[]byte]().Copy()
+		for _, k := range keys {
+			c.Put(1, []byte(k), []byte(k))
+		}
+		ref := []string{}
+		c.Root().Walk(nil, func(k []byte, v *Item[[]byte]) (e bool) {
+			ref = append(ref, string(k))
+			return
+		})
+		return c.Tree(), ref
+	}
+
+	keys := func(c *Copy[[]byte]) []string {
+		out := []string{}
+		c.Root().Walk(nil, func(k []byte, v *Item[[]byte]) (e bool) {
+			out = append(out, string(k))
+			return
+		})
+		return out
+	}
+
+	Convey("Can cut a prefix against a sorted reference", t, func() {
+		for _, k := range trees {
+			tree, ref := build(k)
+			for _, p := range append(probes, ref...) {
+				exp := []string{}
+				for _, k := range ref {
+					if !strings.HasPrefix(k, p) {
+						exp = append(exp, k)
+					}
+				}
+				c := tree.Copy()
+				So(c.CutPrefix([]byte(p)), ShouldEqual, len(ref)-len(exp))
+				So(keys(c), ShouldResemble, exp)
+				So(c.Size(), ShouldEqual, len(exp))
+				So(sized(c.Root()), ShouldBeTrue)
+				So(keys(tree.Copy()), ShouldResemble, ref)
+			}
+		}
+	})
+
+	Convey("Can cut a range against a sorted reference", t, func() {
+		for _, k := range trees {
+			tree, ref := build(k)
+			all := append(append([]string{}, probes...), ref...)
+			for _, beg := range all {
+				for _, end := range append(all, "<nil>") {
+					exp := []string{}
+					for _, k := range ref {
+						if k < beg || end != "<nil>" && k >= end {
+							exp = append(exp, k)
+						}
+					}
+					e := []byte(end)
+					if end == "<nil>" {
+						e = nil
+					}
+					c := tree.Copy()
+					So(c.CutRange([]byte(beg), e), ShouldEqual, len(ref)-len(exp))
+					So(keys(c), ShouldResemble, exp)
+					So(c.Size(), ShouldEqual, len(exp))
+					So(sized(c.Root()), ShouldBeTrue)
+				}
+			}
+			So(keys(tree.Copy()), ShouldResemble, ref)
+		}
+	})
+
+	Convey("Can delete a range at a specific version", t, func() {
+		c := New[[]byte]().Copy()
+		for _, v := range s {
+			c.Put(1, []byte(v), []byte(v))
+		}
+		c.Del(1, []byte(s[13]))
+		So(c.DelRange(2, []byte(s[12]), []byte(s[22])), ShouldEqual, 9)
+		So(c.DelRange(2, []byte(s[12]), []byte(s[22])), ShouldEqual, 0)
+		So(c.Size(), ShouldEqual, len(s))
+		for i, v := range s {
+			if i >= 12 && i < 22 {
+				So(c.Get(2, []byte(v)), ShouldBeNil)
+			} else {
+				So(c.Get(2, []byte(v)), ShouldResemble, []byte(v))
+			}
+		}
+		So(c.Get(1, []byte(s[12])), ShouldResemble, []byte(s[12]))
+		So(c.DelPrefix(3, []byte("/test/zen/")), ShouldEqual, 9)
+		So(c.Get(3, []byte(s[22])), ShouldResemble, []byte(s[22]))
+		So(c.Get(3, []byte(s[23])), ShouldBeNil)
+		So(c.Get(2, []byte(s[23])), ShouldResemble, []byte(s[23]))
+	})
+
+}
+
+func TestLongestPrefix(t *testing.T) {
+
+	c := New[[]byte]().Copy()
+
+	for _, v := range s {
+		c.Put(1, []byte(v), []byte(v))
+	}
+
+	Convey("Can find the longest prefix against a reference", t, func() {
+		for _, v := range s {
+			for _, p := range []string{"", "/", v, v + "/", v + "/1st/x", v[:len(v)-1], "/x" + v} {
+				var exp string
+				for _, k := range s {
+					if strings.HasPrefix(p, k) && len(k) > len(exp) {
+						exp = k
+					}
+				}
+				k, i, ok := c.LongestPrefix([]byte(p))
+				So(ok, ShouldEqual, exp != "")
+				if ok {
+					So(string(k), ShouldEqual, exp)
+					So(i.Get(1), ShouldResemble, []byte(exp))
+				} else {
+					So(k, ShouldBeNil)
+					So(i, ShouldBeNil)
+				}
+			}
+		}
+	})
+
+	Convey("Can find the longest prefix visible at a version", t, func() {
+		c := c.Tree().Copy()
+		c.Del(2, []byte(s[3]))
+		c.Put(3, []byte(s[3]), []byte("NEW"))
+		c.Put(5, []byte(s[4]+"/x"), []byte("NEW"))
+		key := []byte(s[4] + "/x/y")
+		k, _, _ := c.LongestPrefix(key)
+		So(k, ShouldResemble, []byte(s[4]+"/x"))
+		k, _, _ = c.LongestPrefixAt(4, key)
+		So(k, ShouldResemble, []byte(s[4]))
+		c.Del(2, []byte(s[4]))
+		k, _, _ = c.LongestPrefixAt(1, key)
+		So(k, ShouldResemble, []byte(s[4]))
+		k, _, _ = c.LongestPrefixAt(2, key)
+		So(k, ShouldResemble, []byte(s[2]))
+		k, i, ok := c.LongestPrefixAt(3, key)
+		So(ok, ShouldBeTrue)
+		So(k, ShouldResemble, []byte(s[3]))
+		So(i.Get(3), ShouldResemble, []byte("NEW"))
+		k, v, ok := c.Tree().At(2).LongestPrefix(key)
+		So(ok, ShouldBeTrue)
+		So(k, ShouldResemble, []byte(s[2]))
+		So(v, ShouldResemble, []byte(s[2]))
+		_, _, ok = c.LongestPrefixAt(0, key)
+		So(ok, ShouldBeFalse)
+		_, _, ok = c.Tree().At(0).LongestPrefix(key)
+		So(ok, ShouldBeFalse)
+	})
+
+}
+
 func TestUpdate(t *testing.T) {
 
-	c := New().Copy()
+	c := New[[]byte]().Copy()
 
 	Convey("Can insert 1st item", t, func() {
 		val := c.Put(0, []byte("/test"), []byte("ONE"))
@@ -667,7 +1342,7 @@ func TestUpdate(t *testing.T) {
 
 func TestDelete(t *testing.T) {
 
-	c := New().Copy()
+	c := New[[]byte]().Copy()
 
 	Convey("Can insert 1st item", t, func() {
 		val := c.Put(0, []byte("/test"), []byte("TEST"))
@@ -695,6 +1370,527 @@ func TestDelete(t *testing.T) {
 
 }
 
+func TestTombstone(t *testing.T) {
+
+	c := New[[]byte]().Copy()
+
+	Convey("Can insert versioned items", t, func() {
+		for _, v := range s {
+			c.Put(1, []byte(v), []byte(v))
+		}
+		So(c.Put(3, []byte("/test"), []byte("NEW")), ShouldResemble, []byte("/test"))
+		So(c.Size(), ShouldEqual, 35)
+	})
+
+	Convey("Can delete an item at a version", t, func() {
+		val := c.Del(5, []byte("/test"))
+		So(val, ShouldResemble, []byte("NEW"))
+		So(c.Size(), ShouldEqual, 35)
+		So(c.Get(0, []byte("/test")), ShouldBeNil)
+		So(c.Get(1, []byte("/test")), ShouldResemble, []byte("/test"))
+		So(c.Get(4, []byte("/test")), ShouldResemble, []byte("NEW"))
+		So(c.Get(5, []byte("/test")), ShouldBeNil)
+		So(c.Get(7, []byte("/test")), ShouldBeNil)
+	})
+
+	Convey("Can not delete an already deleted item", t, func() {
+		val := c.Del(6, []byte("/test"))
+		So(val, ShouldBeNil)
+		So(c.Get(6, []byte("/test")), ShouldBeNil)
+	})
+
+	Convey("Can not delete an item before its first version", t, func() {
+		val := c.Del(0, []byte("/some"))
+		So(val, ShouldBeNil)
+		So(c.Get(1, []byte("/some")), ShouldResemble, []byte("/some"))
+	})
+
+	Convey("Can walk over the versions with tombstones", t, func() {
+		var vs []uint64
+		var ds []bool
+		c.Root().Walk([]byte("/test"), func(k []byte, v *Item[[]byte]) (e bool) {
+			v.Walk(func(ver uint64, val []byte, del bool) bool {
+				vs = append(vs, ver)
+				ds = append(ds, del)
+				return false
+			})
+			return true
+		})
+		So(vs, ShouldResemble, []uint64{1, 3, 5})
+		So(ds, ShouldResemble, []bool{false, false, true})
+	})
+
+	Convey("Can skip deleted items with `walk`", t, func() {
+		c.Del(5, []byte("/test/one"))
+		i, j := 0, 0
+		c.Root().Walk([]byte("/test"), func(k []byte, v *Item[[]byte]) (e bool) {
+			i++
+			return
+		})
+		c.Root().Walk([]byte("/test"), Live(5, func(k []byte, v *Item[[]byte]) (e bool) {
+			j++
+			return
+		}))
+		So(i, ShouldEqual, 31)
+		So(j, ShouldEqual, 29)
+	})
+
+	Convey("Can skip deleted items with `subs` and `path`", t, func() {
+		i, j := 0, 0
+		c.Root().Subs([]byte("/test/"), Live(5, func(k []byte, v *Item[[]byte]) (e bool) {
+			i++
+			return
+		}))
+		c.Root().Path([]byte("/test/one/sub-one"), Live(4, func(k []byte, v *Item[[]byte]) (e bool) {
+			j++
+			return
+		}))
+		So(i, ShouldEqual, 2)
+		So(j, ShouldEqual, 3)
+	})
+
+	Convey("Can skip deleted items with a cursor", t, func() {
+		i := c.Cursor().Live(5)
+		k, _ := i.Seek([]byte("/test"))
+		So(k, ShouldResemble, []byte("/test/one/sub-one"))
+		k, _ = i.Prev()
+		So(k, ShouldResemble, []byte("/some"))
+		k, _ = i.Next()
+		So(k, ShouldResemble, []byte("/test/one/sub-one"))
+		c.Del(5, []byte("/zoo/some/path"))
+		k, _ = i.Last()
+		So(k, ShouldResemble, []byte("/zoo/some"))
+	})
+
+	Convey("Can insert an item after a tombstone", t, func() {
+		val := c.Put(8, []byte("/test"), []byte("BACK"))
+		So(val, ShouldBeNil)
+		So(c.Get(6, []byte("/test")), ShouldBeNil)
+		So(c.Get(8, []byte("/test")), ShouldResemble, []byte("BACK"))
+	})
+
+}
+
+func TestView(t *testing.T) {
+
+	c := New[[]byte]().Copy()
+
+	for i, v := range s {
+		c.Put(uint64(1+i%2*2), []byte(v), []byte(v))
+	}
+
+	c.Del(5, []byte(s[0]))
+	c.Del(5, []byte(s[34]))
+
+	tree := c.Tree()
+
+	Convey("Can get items at a version", t, func() {
+		So(tree.At(0).Get([]byte(s[0])), ShouldBeNil)
+		So(tree.At(2).Get([]byte(s[0])), ShouldResemble, []byte(s[0]))
+		So(tree.At(2).Get([]byte(s[1])), ShouldBeNil)
+		So(tree.At(4).Get([]byte(s[1])), ShouldResemble, []byte(s[1]))
+		So(tree.At(6).Get([]byte(s[0])), ShouldBeNil)
+		So(tree.At(6).Version(), ShouldEqual, 6)
+	})
+
+	Convey("Can get `min` and `max` at a version", t, func() {
+		k, v := tree.At(0).Min()
+		So(k, ShouldBeNil)
+		So(v, ShouldBeNil)
+		k, v = tree.At(2).Min()
+		So(k, ShouldResemble, []byte(s[0]))
+		So(v, ShouldResemble, []byte(s[0]))
+		k, v = tree.At(2).Max()
+		So(k, ShouldResemble, []byte(s[34]))
+		k, v = tree.At(4).Min()
+		So(k, ShouldResemble, []byte(s[0]))
+		k, v = tree.At(6).Min()
+		So(k, ShouldResemble, []byte(s[1]))
+		k, v = tree.At(6).Max()
+		So(k, ShouldResemble, []byte(s[33]))
+		So(v, ShouldResemble, []byte(s[33]))
+	})
+
+	Convey("Can iterate a view with `walk`", t, func() {
+		for ver, num := range []int{0, 18, 18, 35, 35, 33, 33} {
+			i := 0
+			tree.At(uint64(ver)).Walk(nil, func(k, v []byte) (e bool) {
+				So(v, ShouldResemble, k)
+				i++
+				return
+			})
+			So(i, ShouldEqual, num)
+		}
+	})
+
+	Convey("Can iterate a view with `subs` and `path`", t, func() {
+		i, j := 0, 0
+		tree.At(2).Subs([]byte("/test/"), func(k, v []byte) (e bool) {
+			i++
+			return
+		})
+		tree.At(2).Path([]byte("/test/one/sub-one"), func(k, v []byte) (e bool) {
+			j++
+			return
+		})
+		So(i, ShouldEqual, 3)
+		So(j, ShouldEqual, 1)
+	})
+
+	Convey("Can iterate a view with a cursor", t, func() {
+		var fwd, bwd []string
+		i := tree.At(2).Cursor()
+		for k, v := i.First(); k != nil; k, v = i.Next() {
+			So(v, ShouldResemble, k)
+			fwd = append(fwd, string(k))
+		}
+		for k, _ := i.Last(); k != nil; k, _ = i.Prev() {
+			bwd = append([]string{string(k)}, bwd...)
+		}
+		So(fwd, ShouldHaveLength, 18)
+		So(bwd, ShouldResemble, fwd)
+		k, _ := i.Seek([]byte(s[1]))
+		So(k, ShouldResemble, []byte(s[2]))
+		k, _ = i.Prev()
+		So(k, ShouldResemble, []byte(s[0]))
+	})
+
+}
+
+func TestCompact(t *testing.T) {
+
+	c := New[[]byte]().Copy()
+
+	for _, v := range s {
+		for ver := uint64(1); ver <= 3; ver++ {
+			c.Put(ver, []byte(v), []byte(fmt.Sprint(v, ver)))
+		}
+	}
+
+	tree := c.Tree()
+
+	Convey("Can compact versions below a version", t, func() {
+		vers, keys := c.Compact(2)
+		So(vers, ShouldEqual, 35)
+		So(keys, ShouldEqual, 0)
+		So(c.Size(), ShouldEqual, 35)
+		So(c.Get(1, []byte("/test")), ShouldBeNil)
+		So(c.Get(2, []byte("/test")), ShouldResemble, []byte("/test2"))
+		So(c.Get(3, []byte("/test")), ShouldResemble, []byte("/test3"))
+	})
+
+	Convey("Can compact nothing twice", t, func() {
+		root := c.Root()
+		vers, keys := c.Compact(2)
+		So(vers, ShouldEqual, 0)
+		So(keys, ShouldEqual, 0)
+		So(c.Root(), ShouldEqual, root)
+	})
+
+	Convey("Can compact deleted keys", t, func() {
+		for _, k := range s[3:6] {
+			c.Del(4, []byte(k))
+		}
+		c.Del(4, []byte(s[32]))
+		c.Put(5, []byte(s[32]), []byte("/zoo5"))
+		vers, keys := c.Compact(4)
+		So(vers, ShouldEqual, 31+3*3+2)
+		So(keys, ShouldEqual, 3)
+		So(c.Size(), ShouldEqual, 32)
+		So(c.Get(5, []byte(s[32])), ShouldResemble, []byte("/zoo5"))
+		So(c.Get(4, []byte(s[32])), ShouldBeNil)
+	})
+
+	Convey("Can iterate the compacted tree", t, func() {
+		var keys []string
+		i := c.Cursor()
+		for k, _ := i.First(); k != nil; k, _ = i.Next() {
+			keys = append(keys, string(k))
+		}
+		So(keys, ShouldResemble, append(append([]string{}, s[:3]...), s[6:]...))
+		n := 0
+		c.Root().Walk([]byte("/test/one/sub-o"), func(k []byte, v *Item[[]byte]) (e bool) {
+			n++
+			return
+		})
+		So(n, ShouldEqual, 0)
+	})
+
+	Convey("Can compact without modifying committed trees", t, func() {
+		So(tree.Size(), ShouldEqual, 35)
+		So(tree.At(1).Get([]byte("/test")), ShouldResemble, []byte("/test1"))
+		So(tree.At(3).Get([]byte(s[3])), ShouldResemble, []byte(s[3]+"3"))
+	})
+
+}
+
+func TestDiff(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+
+	items := func(t *Tree[[]byte]) map[string]*Item[[]byte] {
+		m := map[string]*Item[[]byte]{}
+		t.Copy().Root().Walk(nil, func(k []byte, v *Item[[]byte]) (e bool) {
+			m[string(k)] = v
+			return
+		})
+		return m
+	}
+
+	c := New[[]byte]().Copy()
+	for _, v := range s {
+		c.Put(1, []byte(v), []byte(v))
+	}
+	base := c.Tree()
+
+	Convey("Can diff identical trees", t, func() {
+		n := 0
+		Diff(base, base, func(k []byte, a, b *Item[[]byte]) bool {
+			n++
+			return false
+		})
+		So(n, ShouldEqual, 0)
+	})
+
+	Convey("Can diff modified trees", t, func() {
+		for round := 0; round < 50; round++ {
+			c := base.Copy()
+			for op := 0; op < r.Intn(10); op++ {
+				k := []byte(s[r.Intn(len(s))] + []string{"", "/x", "-"}[r.Intn(3)])
+				switch r.Intn(3) {
+				case 0:
+					c.Cut(k)
+				case 1:
+					c.Del(2, k)
+				default:
+					c.Put(2, k, k)
+				}
+			}
+			tree := c.Tree()
+			a, b := items(base), items(tree)
+			var exp, got []string
+			for k, v := range a {
+				if b[k] != v {
+					exp = append(exp, k)
+				}
+			}
+			for k := range b {
+				if a[k] == nil {
+					exp = append(exp, k)
+				}
+			}
+			Diff(base, tree, func(k []byte, x, y *Item[[]byte]) bool {
+				So(x, ShouldEqual, a[string(k)])
+				So(y, ShouldEqual, b[string(k)])
+				got = append(got, string(k))
+				return false
+			})
+			sort.Strings(exp)
+			So(got, ShouldResemble, exp)
+		}
+	})
+
+	Convey("Can diff unrelated trees", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/test/one"), []byte("ONE"))
+		c.Put(1, []byte("/test/zoo"), []byte("ZOO"))
+		var got []string
+		Diff(base, c.Tree(), func(k []byte, x, y *Item[[]byte]) bool {
+			got = append(got, string(k))
+			return false
+		})
+		So(got, ShouldHaveLength, 36)
+		So(got[0], ShouldEqual, s[0])
+		So(got[35], ShouldEqual, s[34])
+	})
+
+	Convey("Can diff trees and exit", t, func() {
+		n := 0
+		Diff(base, New[[]byte](), func(k []byte, x, y *Item[[]byte]) bool {
+			n++
+			return true
+		})
+		So(n, ShouldEqual, 1)
+	})
+
+}
+
+func at(keys []string, i int) []byte {
+	if i < 0 || i >= len(keys) {
+		return nil
+	}
+	return []byte(keys[i])
+}
+
+type model map[string]map[uint64][]byte
+
+func (m model) copy() model {
+	n := make(model, len(m))
+	for k, vs := range m {
+		n[k] = make(map[uint64][]byte, len(vs))
+		for v, x := range vs {
+			n[k][v] = x
+		}
+	}
+	return n
+}
+
+func (m model) get(ver uint64, key string) []byte {
+	var out []byte
+	var max uint64
+	var got bool
+	for v, x := range m[key] {
+		if v <= ver && (!got || v >= max) {
+			out, max, got = x, v, true
+		}
+	}
+	return out
+}
+
+func (m model) del(ver uint64, key string) {
+	if m.get(ver, key) != nil {
+		m[key][ver] = nil
+	}
+}
+
+func (m model) check(t *Tree[[]byte]) bool {
+	if t.Size() != len(m) {
+		return false
+	}
+	c := t.Copy()
+	for k := range m {
+		for v := uint64(0); v <= 10; v++ {
+			if string(c.Get(v, []byte(k))) != string(m.get(v, k)) {
+				return false
+			}
+		}
+	}
+	n := 0
+	c.Root().Walk(nil, func(k []byte, v *Item[[]byte]) (e bool) {
+		n++
+		return
+	})
+	return n == len(m)
+}
+
+func TestSnapshot(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+
+	trees := []*Tree[[]byte]{New[[]byte]()}
+	models := []model{{}}
+
+	Convey("Committed trees are unaffected by interleaved copies", t, func() {
+		for round := 0; round < 50; round++ {
+			var copies []*Copy[[]byte]
+			var states []model
+			for j := 0; j < 4; j++ {
+				x := r.Intn(len(trees))
+				copies = append(copies, trees[x].Copy())
+				states = append(states, models[x].copy())
+			}
+			for op := 0; op < 100; op++ {
+				j := r.Intn(len(copies))
+				c, m := copies[j], states[j]
+				k := s[r.Intn(len(s))]
+				v := uint64(r.Intn(10))
+				switch r.Intn(5) {
+				case 0:
+					c.Cut([]byte(k))
+					delete(m, k)
+				case 1:
+					c.Del(v, []byte(k))
+					if m[k] != nil {
+						m.del(v, k)
+					}
+				default:
+					x := []byte(fmt.Sprint(k, round, op))
+					c.Put(v, []byte(k), x)
+					if m[k] == nil {
+						m[k] = map[uint64][]byte{}
+					}
+					m[k][v] = x
+				}
+			}
+			for j := range copies {
+				trees = append(trees, copies[j].Tree())
+				models = append(models, states[j])
+			}
+		}
+		for j := range trees {
+			So(models[j].check(trees[j]), ShouldBeTrue)
+		}
+	})
+
+	Convey("Committed trees are unaffected by further writes to their copy", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/test"), []byte("ONE"))
+		a := c.Tree()
+		c.Put(1, []byte("/test"), []byte("TWO"))
+		c.Put(2, []byte("/test"), []byte("TRE"))
+		c.Del(1, []byte("/test"))
+		b := c.Tree()
+		So(a.Copy().Get(1, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(a.Copy().Get(2, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(b.Copy().Get(1, []byte("/test")), ShouldBeNil)
+		So(b.Copy().Get(2, []byte("/test")), ShouldResemble, []byte("TRE"))
+	})
+
+	Convey("Committed trees are unaffected by in place changes to their copy", t, func() {
+		c := New[[]byte]().Copy()
+		var trees []*Tree[[]byte]
+		var shapes, dumps []string
+		for op := 0; op < 2000; op++ {
+			k := []byte(s[r.Intn(len(s))])
+			v := uint64(r.Intn(10))
+			switch r.Intn(12) {
+			case 0:
+				c.Cut(k)
+			case 1:
+				c.Del(v, k)
+			case 2:
+				c.CutPrefix(k[:r.Intn(len(k))])
+			case 3:
+				c.CutRange(k, []byte(s[r.Intn(len(s))]))
+			case 4:
+				c.DelRange(v, k, nil)
+			case 5:
+				c.Compact(v)
+			default:
+				c.Put(v, k, []byte(fmt.Sprint(op)))
+			}
+			if op%10 == 0 {
+				t := c.Tree()
+				trees = append(trees, t)
+				shapes = append(shapes, shape(t.root))
+				dumps = append(dumps, fmt.Sprint(dump(t)))
+			}
+		}
+		for i, t := range trees {
+			So(shape(t.root), ShouldEqual, shapes[i])
+			So(fmt.Sprint(dump(t)), ShouldEqual, dumps[i])
+			So(sized(t.root), ShouldBeTrue)
+		}
+	})
+
+	Convey("Nodes created by a copy are modified in place", t, func() {
+		c := New[[]byte]().Copy()
+		for _, v := range s {
+			c.Put(1, []byte(v), []byte(v))
+		}
+		c.Tree()
+		shared := testing.AllocsPerRun(100, func() {
+			c.Put(1, []byte(s[20]), []byte(s[20]))
+			c.Tree()
+		})
+		owned := testing.AllocsPerRun(100, func() {
+			c.Put(1, []byte(s[20]), []byte(s[20]))
+		})
+		So(owned, ShouldBeLessThan, shared)
+	})
+
+}
+
 /*func TestVersion(t *testing.T) {
 
 	c := New().Copy()
@@ -926,3 +2122,33 @@ func TestDelete(t *testing.T) {
 	})
 
 }*/
+
+func benchmarkPut(b *testing.B, commit bool) {
+	keys := make([][]byte, 10000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("/test/%d/%d", i%100, i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := New[[]byte]().Copy()
+		for j, k := range keys {
+			c.Put(uint64(j), k, k)
+			if commit {
+				c.Tree()
+			}
+		}
+	}
+}
+
+// BenchmarkPutBatch inserts keys into a single copy, so that the
+// nodes which the copy has already created are modified in place.
+func BenchmarkPutBatch(b *testing.B) {
+	benchmarkPut(b, false)
+}
+
+// BenchmarkPutCommit commits the copy after each insert, so that
+// every insert has to copy the whole path from the root.
+func BenchmarkPutCommit(b *testing.B) {
+	benchmarkPut(b, true)
+}
@@ -0,0 +1,295 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrConflict is returned when a transaction can not be committed
+	// because a transaction which was committed since it began made
+	// changes which overlap with the keys read or written.
+	ErrConflict = errors.New("vtree: transaction conflict")
+	// ErrTxnClosed is returned when committing a transaction which
+	// has already been committed or rolled back.
+	ErrTxnClosed = errors.New("vtree: transaction closed")
+)
+
+// Isolation represents the isolation level of a transaction.
+type Isolation int
+
+const (
+	// SnapshotIsolation ensures that a transaction reads from a
+	// consistent snapshot of the tree, and that it fails to commit
+	// if another transaction has since written to the same keys.
+	SnapshotIsolation Isolation = iota
+	// SerializableIsolation additionally ensures that a transaction
+	// fails to commit if another transaction has since written to
+	// any of the keys, prefixes, or ranges which it has read.
+	SerializableIsolation
+)
+
+// Manager owns the current tree, and coordinates optimistic
+// transactions on top of it. A Manager is thread safe.
+type Manager[V any] struct {
+	lock sync.Mutex
+	tree *Tree[V]
+	seq  uint64
+	log  []*commit
+	txns map[*Txn[V]]struct{}
+}
+
+type commit struct {
+	seq  uint64
+	keys [][]byte
+}
+
+// NewManager returns a transaction manager for the specified tree.
+func NewManager[V any](t *Tree[V]) *Manager[V] {
+	return &Manager[V]{tree: t, txns: make(map[*Txn[V]]struct{})}
+}
+
+// Tree returns the most recently committed tree.
+func (m *Manager[V]) Tree() *Tree[V] {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.tree
+}
+
+// Begin starts a new transaction on the most recently committed
+// tree, using the specified isolation level.
+func (m *Manager[V]) Begin(iso Isolation) *Txn[V] {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	t := &Txn[V]{
+		iso:  iso,
+		mgr:  m,
+		base: m.seq,
+		copy: m.tree.Copy(),
+	}
+	m.txns[t] = struct{}{}
+	return t
+}
+
+func (m *Manager[V]) end(t *Txn[V]) {
+	delete(m.txns, t)
+	min := m.seq
+	for x := range m.txns {
+		if x.base < min {
+			min = x.base
+		}
+	}
+	for len(m.log) > 0 && m.log[0].seq <= min {
+		m.log[0] = nil
+		m.log = m.log[1:]
+	}
+}
+
+// Txn represents an optimistic transaction which records the keys
+// which it reads and writes, so that any conflicts with concurrent
+// transactions can be detected on commit. A Txn is not thread safe.
+type Txn[V any] struct {
+	iso  Isolation
+	mgr  *Manager[V]
+	base uint64
+	copy *Copy[V]
+	done bool
+	ops  []*op[V]
+	keys [][]byte
+	pres [][]byte
+	rngs []*span
+}
+
+// span represents an inclusive range of keys read with a cursor.
+type span struct {
+	beg, end []byte
+	neg, pos bool
+	set      bool
+}
+
+func (s *span) add(k []byte) {
+	switch {
+	case !s.set:
+		s.beg, s.end, s.set = k, k, true
+	case bytes.Compare(k, s.beg) < 0:
+		s.beg = k
+	case bytes.Compare(k, s.end) > 0:
+		s.end = k
+	}
+}
+
+func (s *span) has(k []byte) bool {
+	return (s.neg || s.set && bytes.Compare(k, s.beg) >= 0) &&
+		(s.pos || s.set && bytes.Compare(k, s.end) <= 0)
+}
+
+// Get is used to retrieve a specific key, returning the current value.
+func (t *Txn[V]) Get(ver uint64, key []byte) V {
+	t.keys = append(t.keys, key)
+	return t.copy.Get(ver, key)
+}
+
+// Put is used to insert a specific key, returning the previous value.
+func (t *Txn[V]) Put(ver uint64, key []byte, val V) V {
+	t.ops = append(t.ops, &op[V]{kind: opPut, ver: ver, key: key, val: val})
+	return t.copy.Put(ver, key, val)
+}
+
+// Del is used to delete a given key at a specific version, returning
+// the previous value.
+func (t *Txn[V]) Del(ver uint64, key []byte) V {
+	t.ops = append(t.ops, &op[V]{kind: opDel, ver: ver, key: key})
+	return t.copy.Del(ver, key)
+}
+
+// Cut is used to delete a given key, returning the previous value.
+func (t *Txn[V]) Cut(key []byte) V {
+	t.ops = append(t.ops, &op[V]{kind: opCut, key: key})
+	return t.copy.Cut(key)
+}
+
+// Walk is used to iterate over all of the items under the specified
+// prefix, recording the whole prefix as having been read.
+func (t *Txn[V]) Walk(prefix []byte, f Walker[V]) {
+	t.pres = append(t.pres, prefix)
+	t.copy.root.Walk(prefix, f)
+}
+
+// Cursor returns a new cursor for iterating through the transaction.
+// The range of keys which the cursor passes over is recorded as having
+// been read, including any keys which are not present in the tree.
+func (t *Txn[V]) Cursor() *Cursor[V] {
+	s := &span{}
+	t.rngs = append(t.rngs, s)
+	return &Cursor[V]{tree: t.copy, txn: t, rng: s}
+}
+
+// Commit attempts to commit the transaction to the manager. If any
+// transaction committed since this transaction began conflicts with
+// it, then the transaction is discarded and ErrConflict is returned.
+func (t *Txn[V]) Commit() error {
+
+	if t.done {
+		return ErrTxnClosed
+	}
+
+	m := t.mgr
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	t.done = true
+
+	defer m.end(t)
+
+	for _, c := range m.log {
+		if c.seq > t.base && t.conflicts(c) {
+			return ErrConflict
+		}
+	}
+
+	if len(t.ops) == 0 {
+		return nil
+	}
+
+	// If nothing has been committed since
+	// this transaction began, then we can
+	// use the transaction tree directly,
+	// otherwise we replay the changes on
+	// top of the latest committed tree.
+
+	if m.seq == t.base {
+		m.tree = t.copy.Tree()
+	} else {
+		c := m.tree.Copy()
+		for _, o := range t.ops {
+			o.apply(c)
+		}
+		m.tree = c.Tree()
+	}
+
+	m.seq++
+
+	keys := make([][]byte, len(t.ops))
+	for i, o := range t.ops {
+		keys[i] = o.key
+	}
+
+	m.log = append(m.log, &commit{seq: m.seq, keys: keys})
+
+	return nil
+
+}
+
+// Rollback discards the transaction, and any changes made within it.
+func (t *Txn[V]) Rollback() error {
+
+	if t.done {
+		return ErrTxnClosed
+	}
+
+	m := t.mgr
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	t.done = true
+
+	m.end(t)
+
+	return nil
+
+}
+
+func (t *Txn[V]) conflicts(c *commit) bool {
+
+	for _, k := range c.keys {
+
+		for _, o := range t.ops {
+			if bytes.Equal(k, o.key) {
+				return true
+			}
+		}
+
+		if t.iso != SerializableIsolation {
+			continue
+		}
+
+		for _, r := range t.keys {
+			if bytes.Equal(k, r) {
+				return true
+			}
+		}
+
+		for _, p := range t.pres {
+			if bytes.HasPrefix(k, p) {
+				return true
+			}
+		}
+
+		for _, s := range t.rngs {
+			if s.has(k) {
+				return true
+			}
+		}
+
+	}
+
+	return false
+
+}
@@ -0,0 +1,158 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTxn(t *testing.T) {
+
+	c := New[[]byte]().Copy()
+	for _, v := range s {
+		c.Put(1, []byte(v), []byte(v))
+	}
+
+	Convey("Can commit a transaction", t, func() {
+		m := NewManager(c.Tree())
+		x := m.Begin(SnapshotIsolation)
+		So(x.Get(1, []byte("/test")), ShouldResemble, []byte("/test"))
+		x.Put(2, []byte("/test"), []byte("NEW"))
+		So(m.Tree().At(2).Get([]byte("/test")), ShouldResemble, []byte("/test"))
+		So(x.Commit(), ShouldBeNil)
+		So(m.Tree().At(2).Get([]byte("/test")), ShouldResemble, []byte("NEW"))
+		So(x.Commit(), ShouldEqual, ErrTxnClosed)
+		So(x.Rollback(), ShouldEqual, ErrTxnClosed)
+		So(m.log, ShouldBeEmpty)
+	})
+
+	Convey("Can rollback a transaction", t, func() {
+		m := NewManager(c.Tree())
+		x := m.Begin(SnapshotIsolation)
+		x.Cut([]byte("/test"))
+		So(x.Rollback(), ShouldBeNil)
+		So(x.Commit(), ShouldEqual, ErrTxnClosed)
+		So(m.Tree().Size(), ShouldEqual, 35)
+	})
+
+	Convey("Can commit disjoint transactions", t, func() {
+		m := NewManager(c.Tree())
+		x := m.Begin(SnapshotIsolation)
+		y := m.Begin(SnapshotIsolation)
+		x.Put(2, []byte("/test"), []byte("X"))
+		x.Cut([]byte("/some"))
+		y.Put(2, []byte("/zoo"), []byte("Y"))
+		y.Del(2, []byte("/test/one"))
+		So(x.Commit(), ShouldBeNil)
+		So(y.Commit(), ShouldBeNil)
+		v := m.Tree().At(2)
+		So(m.Tree().Size(), ShouldEqual, 34)
+		So(v.Get([]byte("/test")), ShouldResemble, []byte("X"))
+		So(v.Get([]byte("/zoo")), ShouldResemble, []byte("Y"))
+		So(v.Get([]byte("/test/one")), ShouldBeNil)
+		So(v.Get([]byte("/some")), ShouldBeNil)
+		So(m.log, ShouldBeEmpty)
+	})
+
+	Convey("Can detect write conflicts", t, func() {
+		m := NewManager(c.Tree())
+		x := m.Begin(SnapshotIsolation)
+		y := m.Begin(SnapshotIsolation)
+		x.Put(2, []byte("/test"), []byte("X"))
+		y.Put(2, []byte("/test"), []byte("Y"))
+		So(x.Commit(), ShouldBeNil)
+		So(y.Commit(), ShouldEqual, ErrConflict)
+		So(m.Tree().At(2).Get([]byte("/test")), ShouldResemble, []byte("X"))
+		So(m.log, ShouldBeEmpty)
+	})
+
+	Convey("Can allow write skew with snapshot isolation", t, func() {
+		m := NewManager(c.Tree())
+		x := m.Begin(SnapshotIsolation)
+		y := m.Begin(SnapshotIsolation)
+		x.Get(1, []byte("/some"))
+		x.Put(2, []byte("/zoo"), []byte("X"))
+		y.Get(1, []byte("/zoo"))
+		y.Put(2, []byte("/some"), []byte("Y"))
+		So(x.Commit(), ShouldBeNil)
+		So(y.Commit(), ShouldBeNil)
+	})
+
+	Convey("Can detect read conflicts with serializable isolation", t, func() {
+		m := NewManager(c.Tree())
+		x := m.Begin(SerializableIsolation)
+		y := m.Begin(SerializableIsolation)
+		x.Get(1, []byte("/some"))
+		x.Put(2, []byte("/zoo"), []byte("X"))
+		y.Get(1, []byte("/zoo"))
+		y.Put(2, []byte("/some"), []byte("Y"))
+		So(x.Commit(), ShouldBeNil)
+		So(y.Commit(), ShouldEqual, ErrConflict)
+	})
+
+	Convey("Can detect prefix conflicts with serializable isolation", t, func() {
+		m := NewManager(c.Tree())
+		x := m.Begin(SerializableIsolation)
+		y := m.Begin(SerializableIsolation)
+		z := m.Begin(SerializableIsolation)
+		x.Walk([]byte("/test/zen"), func(k []byte, v *Item[[]byte]) (e bool) {
+			return
+		})
+		x.Put(2, []byte("/some"), []byte("X"))
+		z.Walk([]byte("/test/one"), func(k []byte, v *Item[[]byte]) (e bool) {
+			return
+		})
+		z.Put(2, []byte("/zoo"), []byte("Z"))
+		y.Put(2, []byte("/test/zen/new"), []byte("Y"))
+		So(y.Commit(), ShouldBeNil)
+		So(x.Commit(), ShouldEqual, ErrConflict)
+		So(z.Commit(), ShouldBeNil)
+	})
+
+	Convey("Can detect range conflicts with serializable isolation", t, func() {
+		m := NewManager(c.Tree())
+		x := m.Begin(SerializableIsolation)
+		y := m.Begin(SerializableIsolation)
+		z := m.Begin(SerializableIsolation)
+		i := x.Cursor()
+		i.Seek([]byte("/test/one/sub-two"))
+		i.Next()
+		x.Put(2, []byte("/some"), []byte("X"))
+		z.Put(2, []byte("/test/one/sub-zen/1st"), []byte("Z"))
+		y.Put(2, []byte("/test/one/sub-two/0th"), []byte("Y"))
+		So(z.Commit(), ShouldBeNil)
+		So(y.Commit(), ShouldBeNil)
+		So(x.Commit(), ShouldEqual, ErrConflict)
+	})
+
+	Convey("Can delete with a transaction cursor", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(0, []byte("/test"), []byte("/test"))
+		m := NewManager(c.Tree())
+		x := m.Begin(SnapshotIsolation)
+		y := m.Begin(SnapshotIsolation)
+		i := x.Cursor()
+		i.Seek([]byte("/test"))
+		i.Del()
+		y.Put(0, []byte("/zoo"), []byte("Y"))
+		So(y.Commit(), ShouldBeNil)
+		So(x.Commit(), ShouldBeNil)
+		So(m.Tree().At(0).Get([]byte("/test")), ShouldBeNil)
+		So(m.Tree().At(0).Get([]byte("/zoo")), ShouldResemble, []byte("Y"))
+	})
+
+}
@@ -0,0 +1,150 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type person struct {
+	name string
+	age  int
+}
+
+func TestTyped(t *testing.T) {
+
+	Convey("Can store and retrieve typed values", t, func() {
+		c := New[int]().Copy()
+		So(c.Put(1, []byte("/a"), 10), ShouldEqual, 0)
+		So(c.Put(2, []byte("/a"), 20), ShouldEqual, 10)
+		So(c.Put(1, []byte("/b"), 30), ShouldEqual, 0)
+		So(c.Del(3, []byte("/a")), ShouldEqual, 20)
+		So(c.Get(1, []byte("/a")), ShouldEqual, 10)
+		So(c.Get(2, []byte("/a")), ShouldEqual, 20)
+		So(c.Get(3, []byte("/a")), ShouldEqual, 0)
+		So(c.Get(1, []byte("/none")), ShouldEqual, 0)
+		So(c.Cut([]byte("/b")), ShouldEqual, 30)
+		So(c.Size(), ShouldEqual, 1)
+		var vals []int
+		k, i := c.Cursor().First()
+		So(k, ShouldResemble, []byte("/a"))
+		i.Walk(func(ver uint64, val int, del bool) bool {
+			vals = append(vals, val)
+			return false
+		})
+		So(vals, ShouldResemble, []int{10, 20, 0})
+	})
+
+	Convey("Can tell stored zero values from missing keys", t, func() {
+		c := New[int]().Copy()
+		c.Put(1, []byte("/zero"), 0)
+		c.Put(1, []byte("/gone"), 1)
+		c.Del(2, []byte("/gone"))
+		val, ok := c.Lookup(1, []byte("/zero"))
+		So(val, ShouldEqual, 0)
+		So(ok, ShouldBeTrue)
+		_, ok = c.Lookup(0, []byte("/zero"))
+		So(ok, ShouldBeFalse)
+		_, ok = c.Lookup(1, []byte("/none"))
+		So(ok, ShouldBeFalse)
+		val, ok = c.Lookup(1, []byte("/gone"))
+		So(val, ShouldEqual, 1)
+		So(ok, ShouldBeTrue)
+		_, ok = c.Lookup(2, []byte("/gone"))
+		So(ok, ShouldBeFalse)
+		p := New[person]().Copy()
+		p.Put(1, []byte("/zero"), person{})
+		v := p.Tree().At(1)
+		got, ok := v.Lookup([]byte("/zero"))
+		So(got, ShouldResemble, person{})
+		So(ok, ShouldBeTrue)
+		_, ok = v.Lookup([]byte("/none"))
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Can store pointers without copying them", t, func() {
+		p := &person{name: "Alice", age: 30}
+		c := New[*person]().Copy()
+		c.Put(1, []byte("/person"), p)
+		tr := c.Tree()
+		So(tr.At(1).Get([]byte("/person")), ShouldEqual, p)
+		So(tr.At(1).Get([]byte("/other")), ShouldBeNil)
+		k, v := tr.At(1).Min()
+		So(k, ShouldResemble, []byte("/person"))
+		So(v, ShouldEqual, p)
+	})
+
+	Convey("Can read typed values without allocating", t, func() {
+		c := New[int]().Copy()
+		for i, k := range s {
+			c.Put(1, []byte(k), i)
+		}
+		v := c.Tree().At(1)
+		key := []byte("/test/one/sub-two/1st")
+		So(v.Get(key), ShouldEqual, 7)
+		So(testing.AllocsPerRun(100, func() {
+			v.Get(key)
+		}), ShouldEqual, 0)
+	})
+
+	Convey("Can subscribe to typed changes", t, func() {
+		st := NewStore[person](nil)
+		sub := st.Subscribe(1)
+		defer sub.Close()
+		So(st.Update(func(c *Copy[person]) error {
+			c.Put(1, []byte("/a"), person{name: "A", age: 1})
+			return nil
+		}), ShouldBeNil)
+		cs := <-sub.C
+		So(cs.Changes, ShouldResemble, []Change[person]{
+			{Op: OpPut, Key: []byte("/a"), Ver: 1, New: person{name: "A", age: 1}},
+		})
+	})
+
+	Convey("Can not encode values which are not byte slices", t, func() {
+		c := New[int]().Copy()
+		c.Put(1, []byte("/a"), 1)
+		c.Del(2, []byte("/a"))
+		tr := c.Tree()
+		var buf bytes.Buffer
+		_, err := tr.WriteTo(&buf)
+		So(err, ShouldEqual, ErrValueType)
+		_, err = tr.ExportSince(0, &buf)
+		So(err, ShouldEqual, ErrValueType)
+		b := &Batch[int]{}
+		b.Put(1, []byte("/a"), 1)
+		_, err = b.MarshalBinary()
+		So(err, ShouldEqual, ErrValueType)
+	})
+
+	Convey("Can not decode into values which are not byte slices", t, func() {
+		c := New[[]byte]().Copy()
+		c.Put(1, []byte("/a"), []byte("A"))
+		var buf bytes.Buffer
+		_, err := c.Tree().WriteTo(&buf)
+		So(err, ShouldBeNil)
+		_, err = New[int]().ReadFrom(&buf)
+		So(err, ShouldEqual, ErrValueType)
+		b := &Batch[[]byte]{}
+		b.Put(1, []byte("/a"), []byte("A"))
+		data, err := b.MarshalBinary()
+		So(err, ShouldBeNil)
+		So((&Batch[int]{}).UnmarshalBinary(data), ShouldEqual, ErrValueType)
+	})
+
+}
@@ -0,0 +1,162 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+// View represents a read-only view of a tree at a specific version.
+// All values are resolved at that version, and any keys which have
+// no value visible at that version are hidden.
+type View[V any] struct {
+	ver  uint64
+	tree *Copy[V]
+}
+
+// Visitor represents a callback function which is to be used when
+// iterating through a view using Path, Subs, or Walk. It will be
+// populated with the key and the value at the version of the view,
+// and returns a bool signifying if the iteration should be terminated.
+type Visitor[V any] func(key []byte, val V) (exit bool)
+
+// Version returns the version at which the view resolves values.
+func (v *View[V]) Version() uint64 {
+	return v.ver
+}
+
+// Get is used to retrieve a specific key, returning the value visible
+// at the version of the view.
+func (v *View[V]) Get(key []byte) V {
+	return v.tree.Get(v.ver, key)
+}
+
+// Lookup is used to retrieve a specific key, returning the value visible
+// at the version of the view, and whether any value is visible at all.
+func (v *View[V]) Lookup(key []byte) (V, bool) {
+	return v.tree.Lookup(v.ver, key)
+}
+
+// LongestPrefix returns the key and value of the longest key which is
+// a prefix of the specified key, and which is visible in the view. If
+// no such key exists, then ok is false.
+func (v *View[V]) LongestPrefix(key []byte) (_ []byte, val V, ok bool) {
+	k, i, ok := v.tree.LongestPrefixAt(v.ver, key)
+	if !ok {
+		return nil, val, false
+	}
+	return k, i.Get(v.ver), true
+}
+
+// Min returns the key and value of the minimum visible item.
+func (v *View[V]) Min() ([]byte, V) {
+	return v.Cursor().First()
+}
+
+// Max returns the key and value of the maximum visible item.
+func (v *View[V]) Max() ([]byte, V) {
+	return v.Cursor().Last()
+}
+
+// Cursor returns a new cursor for iterating through the view.
+func (v *View[V]) Cursor() *ViewCursor[V] {
+	return &ViewCursor[V]{ver: v.ver, cur: v.tree.Cursor()}
+}
+
+// Path is used to recurse over the view only visiting items
+// which are above the specified key in the tree.
+func (v *View[V]) Path(k []byte, f Visitor[V]) {
+	v.tree.root.Path(k, v.walker(f))
+}
+
+// Subs is used to recurse over the view only visiting items
+// which are directly under the specified key in the tree.
+func (v *View[V]) Subs(k []byte, f Visitor[V]) {
+	v.tree.root.Subs(k, v.walker(f))
+}
+
+// Walk is used to recurse over the view only visiting items
+// which are under the specified key in the tree.
+func (v *View[V]) Walk(k []byte, f Visitor[V]) {
+	v.tree.root.Walk(k, v.walker(f))
+}
+
+func (v *View[V]) walker(f Visitor[V]) Walker[V] {
+	return func(key []byte, val *Item[V]) (exit bool) {
+		if !val.live(v.ver) {
+			return false
+		}
+		return f(key, val.Get(v.ver))
+	}
+}
+
+// ViewCursor represents an iterator that can traverse over all of
+// the visible key-value pairs in a view in sorted order.
+type ViewCursor[V any] struct {
+	ver uint64
+	cur *Cursor[V]
+}
+
+// First moves the cursor to the first visible item in the view and
+// returns its key and value. If there are no visible items then a
+// nil key and the zero value are returned.
+func (c *ViewCursor[V]) First() ([]byte, V) {
+	return c.fwd(c.cur.First())
+}
+
+// Last moves the cursor to the last visible item in the view and
+// returns its key and value. If there are no visible items then a
+// nil key and the zero value are returned.
+func (c *ViewCursor[V]) Last() ([]byte, V) {
+	return c.bwd(c.cur.Last())
+}
+
+// Prev moves the cursor to the previous visible item in the view and
+// returns its key and value. If the cursor is at the start of the view
+// then a nil key and the zero value are returned.
+func (c *ViewCursor[V]) Prev() ([]byte, V) {
+	return c.bwd(c.cur.Prev())
+}
+
+// Next moves the cursor to the next visible item in the view and
+// returns its key and value. If the cursor is at the end of the view
+// then a nil key and the zero value are returned.
+func (c *ViewCursor[V]) Next() ([]byte, V) {
+	return c.fwd(c.cur.Next())
+}
+
+// Seek moves the cursor to a given key in the view and returns it.
+// If the specified key is not visible then the next visible key is
+// used. If no keys follow, then a nil key and the zero value are
+// returned.
+func (c *ViewCursor[V]) Seek(key []byte) ([]byte, V) {
+	return c.fwd(c.cur.Seek(key))
+}
+
+func (c *ViewCursor[V]) fwd(k []byte, v *Item[V]) (_ []byte, val V) {
+	for v != nil && !v.live(c.ver) {
+		k, v = c.cur.Next()
+	}
+	if v == nil {
+		return nil, val
+	}
+	return k, v.Get(c.ver)
+}
+
+func (c *ViewCursor[V]) bwd(k []byte, v *Item[V]) (_ []byte, val V) {
+	for v != nil && !v.live(c.ver) {
+		k, v = c.cur.Prev()
+	}
+	if v == nil {
+		return nil, val
+	}
+	return k, v.Get(c.ver)
+}
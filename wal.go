@@ -0,0 +1,469 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A database directory, as managed by Open, contains a write-ahead log
+// named "wal", and one or more snapshots named after the sequence of
+// the last commit which they contain, such as "000000000000002a.snap".
+// Each commit is appended to the log as a single record:
+//
+//	len         4 bytes   big-endian length of the payload
+//	crc         4 bytes   big-endian CRC-32C of the payload
+//	payload:
+//	  seq       varint    the commit sequence number
+//	  batch     ...       the operations, as encoded by Batch.MarshalBinary
+
+const (
+	walName = "wal"
+	walSnap = ".snap"
+	walTemp = ".tmp"
+)
+
+var (
+	// ErrClosed is returned when using a database which has been closed.
+	ErrClosed = errors.New("vtree: database closed")
+	// ErrLogFormat is returned when the write-ahead log contains a
+	// complete record which can not be decoded, or which fails its
+	// checksum while being followed by further records.
+	ErrLogFormat = errors.New("vtree: invalid log record")
+)
+
+// SyncMode specifies when commits are flushed to stable storage.
+type SyncMode int
+
+const (
+	// SyncAlways flushes the write-ahead log to stable storage before
+	// each commit is published, so that no acknowledged commit is lost.
+	SyncAlways SyncMode = iota
+	// SyncNever leaves flushing the write-ahead log to the operating
+	// system, so that the most recent commits may be lost in a crash.
+	SyncNever
+)
+
+// Option configures a database when it is opened.
+type Option func(*DB)
+
+// WithSync specifies when commits are flushed to stable storage.
+func WithSync(mode SyncMode) Option {
+	return func(d *DB) {
+		d.sync = mode
+	}
+}
+
+// DB is a tree which is persisted to a directory on disk, using a
+// write-ahead log of every commit along with periodic snapshots. It
+// is safe for concurrent use, with readers never blocking, and with
+// writers being serialized.
+type DB struct {
+	lock  sync.Mutex
+	store *Store[[]byte]
+	path  string
+	file  *os.File
+	sync  SyncMode
+	seq   uint64
+	off   int64
+}
+
+// Open opens the database in the specified directory, creating it if
+// it does not exist. The most recent tree is recovered by loading the
+// newest snapshot, and then replaying any subsequent commits from the
+// write-ahead log. A partially written record at the end of the log,
+// as left by a crash, is discarded.
+func Open(path string, opts ...Option) (*DB, error) {
+
+	d := &DB{path: path}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+
+	tree, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+
+	d.file, err = os.OpenFile(filepath.Join(path, walName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if tree, err = d.replay(tree); err != nil {
+		d.file.Close()
+		return nil, err
+	}
+
+	d.store = NewStore(tree)
+
+	return d, nil
+
+}
+
+// Load returns the most recently committed tree.
+func (d *DB) Load() *Tree[[]byte] {
+	return d.store.Load()
+}
+
+// View calls the function with the current tree, providing a
+// consistent read scope which is unaffected by concurrent updates.
+func (d *DB) View(fn func(*Tree[[]byte]) error) error {
+	return d.store.View(fn)
+}
+
+// Subscribe returns a new subscription to the commits made to the
+// database, buffering up to the specified number of commits.
+func (d *DB) Subscribe(size int) *Subscription[[]byte] {
+	return d.store.Subscribe(size)
+}
+
+// Update applies changes to a copy of the current tree. If the function
+// returns an error then the changes are discarded, otherwise the Put,
+// Del, Cut and Compact operations applied to the copy are appended to
+// the write-ahead log as a single record, before the new tree is
+// published atomically to all subsequent readers.
+func (d *DB) Update(fn func(*Copy[[]byte]) error) error {
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.file == nil {
+		return ErrClosed
+	}
+
+	return d.store.Update(func(c *Copy[[]byte]) error {
+		c.log = &Batch[[]byte]{}
+		defer func() {
+			c.log = nil
+		}()
+		if err := fn(c); err != nil {
+			return err
+		}
+		if c.log.Len() == 0 {
+			return nil
+		}
+		return d.append(c.log)
+	})
+
+}
+
+// Checkpoint writes a snapshot of the current tree, and then truncates
+// the write-ahead log, so that subsequent recovery only needs to replay
+// commits made after the checkpoint. Older snapshots are removed.
+func (d *DB) Checkpoint() error {
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.file == nil {
+		return ErrClosed
+	}
+
+	name := filepath.Join(d.path, fmt.Sprintf("%016x%s", d.seq, walSnap))
+
+	if err := d.snapshot(name, d.store.Load()); err != nil {
+		return err
+	}
+
+	if err := d.file.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	d.off = 0
+
+	if err := d.file.Sync(); err != nil {
+		return err
+	}
+
+	snaps, err := d.snaps()
+	if err != nil {
+		return err
+	}
+
+	for _, seq := range snaps {
+		if seq < d.seq {
+			os.Remove(filepath.Join(d.path, fmt.Sprintf("%016x%s", seq, walSnap)))
+		}
+	}
+
+	return nil
+
+}
+
+// Close flushes and closes the write-ahead log. The most recently
+// committed tree remains readable, but no further updates can be made.
+func (d *DB) Close() error {
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.file == nil {
+		return ErrClosed
+	}
+
+	err := d.file.Sync()
+
+	if e := d.file.Close(); err == nil {
+		err = e
+	}
+
+	d.file = nil
+
+	return err
+
+}
+
+// ---------------------------------------------------------------------------
+
+func (d *DB) append(b *Batch[[]byte]) error {
+
+	seq := d.seq + 1
+
+	rec := make([]byte, 8, 64)
+	rec = appendUvarint(rec, seq)
+
+	rec, err := b.encode(rec)
+	if err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint32(rec[0:4], uint32(len(rec)-8))
+	binary.BigEndian.PutUint32(rec[4:8], crc32.Checksum(rec[8:], snapTable))
+
+	if _, err := d.file.Write(rec); err != nil {
+		d.rewind()
+		return err
+	}
+
+	if d.sync == SyncAlways {
+		if err := d.file.Sync(); err != nil {
+			d.rewind()
+			return err
+		}
+	}
+
+	d.seq, d.off = seq, d.off+int64(len(rec))
+
+	return nil
+
+}
+
+// rewind removes any partially written record from the end of the
+// log, so that subsequent records are not appended after it.
+func (d *DB) rewind() {
+	if d.file.Truncate(d.off) == nil {
+		d.file.Seek(d.off, io.SeekStart)
+	}
+}
+
+func (d *DB) snaps() ([]uint64, error) {
+	names, err := filepath.Glob(filepath.Join(d.path, "*"+walSnap))
+	if err != nil {
+		return nil, err
+	}
+	var out []uint64
+	for _, name := range names {
+		hex := strings.TrimSuffix(filepath.Base(name), walSnap)
+		if seq, err := strconv.ParseUint(hex, 16, 64); err == nil {
+			out = append(out, seq)
+		}
+	}
+	return out, nil
+}
+
+func (d *DB) load() (*Tree[[]byte], error) {
+
+	tmps, _ := filepath.Glob(filepath.Join(d.path, "*"+walTemp))
+	for _, name := range tmps {
+		os.Remove(name)
+	}
+
+	snaps, err := d.snaps()
+	if err != nil {
+		return nil, err
+	}
+
+	tree := New[[]byte]()
+
+	if len(snaps) == 0 {
+		return tree, nil
+	}
+
+	for _, seq := range snaps {
+		if seq > d.seq {
+			d.seq = seq
+		}
+	}
+
+	f, err := os.Open(filepath.Join(d.path, fmt.Sprintf("%016x%s", d.seq, walSnap)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	if _, err := tree.ReadFrom(bufio.NewReader(f)); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+
+}
+
+func (d *DB) snapshot(name string, t *Tree[[]byte]) error {
+
+	f, err := os.Create(name + walTemp)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.WriteTo(f)
+
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	if err == nil {
+		err = os.Rename(name+walTemp, name)
+	}
+
+	if err != nil {
+		os.Remove(name + walTemp)
+		return err
+	}
+
+	dir, err := os.Open(d.path)
+	if err != nil {
+		return err
+	}
+
+	defer dir.Close()
+
+	return dir.Sync()
+
+}
+
+func (d *DB) replay(t *Tree[[]byte]) (*Tree[[]byte], error) {
+
+	info, err := d.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	c := t.Copy()
+	r := bufio.NewReader(d.file)
+	base := d.seq
+
+	for {
+
+		seq, b, n, err := record(r, info.Size()-d.off)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			if err = d.file.Truncate(d.off); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if seq > base {
+			b.apply(c)
+			d.seq = seq
+		}
+
+		d.off += n
+
+	}
+
+	if _, err := d.file.Seek(d.off, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return c.Tree(), nil
+
+}
+
+// record reads a single record from the log, where max is the number
+// of bytes remaining in the log. It returns io.EOF at the end of the
+// log, or io.ErrUnexpectedEOF if the record is incomplete, or is the
+// last record in the log and is corrupt, as left by a torn write. A
+// corrupt record which is followed by further records returns
+// ErrLogFormat, so that later commits are not discarded.
+func record(r io.Reader, max int64) (uint64, *Batch[[]byte], int64, error) {
+
+	var hdr [8]byte
+
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, 0, err
+	}
+
+	size := int64(binary.BigEndian.Uint32(hdr[0:4]))
+	if size > max-8 {
+		return 0, nil, 0, io.ErrUnexpectedEOF
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, nil, 0, eof(err)
+	}
+
+	if crc32.Checksum(buf, snapTable) != binary.BigEndian.Uint32(hdr[4:8]) {
+		if 8+size < max {
+			return 0, nil, 0, ErrLogFormat
+		}
+		return 0, nil, 0, io.ErrUnexpectedEOF
+	}
+
+	seq, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return 0, nil, 0, ErrLogFormat
+	}
+
+	b := &Batch[[]byte]{}
+	if err := b.decode(buf[n:]); err != nil {
+		return 0, nil, 0, ErrLogFormat
+	}
+
+	return seq, b, 8 + size, nil
+
+}
@@ -0,0 +1,282 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWAL(t *testing.T) {
+
+	Convey("Can open an empty database", t, func() {
+		db, err := Open(t.TempDir())
+		So(err, ShouldBeNil)
+		So(db.Load().Size(), ShouldEqual, 0)
+		So(db.Close(), ShouldBeNil)
+		So(db.Close(), ShouldEqual, ErrClosed)
+		So(db.Update(func(c *Copy[[]byte]) error { return nil }), ShouldEqual, ErrClosed)
+		So(db.Checkpoint(), ShouldEqual, ErrClosed)
+	})
+
+	Convey("Can recover commits from the log", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/test"), []byte("ONE"))
+			c.Put(2, []byte("/test"), []byte("TWO"))
+			c.Put(1, []byte("/nil"), nil)
+			c.Put(1, []byte(""), []byte("ROOT"))
+			c.Put(1, []byte("/cut"), []byte("CUT"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Del(3, []byte("/test"))
+			c.Cut([]byte("/cut"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(9, []byte("/test"), []byte("LOST"))
+			return errors.New("discarded")
+		}), ShouldNotBeNil)
+		So(db.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		c := db.Load().Copy()
+		So(c.Size(), ShouldEqual, 3)
+		So(c.Get(1, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(c.Get(2, []byte("/test")), ShouldResemble, []byte("TWO"))
+		So(c.Get(9, []byte("/test")), ShouldBeNil)
+		So(c.Root().get([]byte("/test")).Deleted(3), ShouldBeTrue)
+		So(c.Root().get([]byte("/nil")), ShouldNotBeNil)
+		So(c.Get(1, []byte("/nil")), ShouldBeNil)
+		So(c.Get(1, []byte("")), ShouldResemble, []byte("ROOT"))
+		So(c.Get(1, []byte("/cut")), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+	})
+
+	Convey("Can recover commits without syncing", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir, WithSync(SyncNever))
+		So(err, ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/test"), []byte("ONE"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Load().Copy().Get(1, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(db.Close(), ShouldBeNil)
+	})
+
+	Convey("Can recover compaction from the log", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/test"), []byte("ONE"))
+			c.Put(2, []byte("/test"), []byte("TWO"))
+			c.Compact(2)
+			return nil
+		}), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Load().Copy().Get(1, []byte("/test")), ShouldBeNil)
+		So(db.Load().Copy().Get(2, []byte("/test")), ShouldResemble, []byte("TWO"))
+		So(db.Close(), ShouldBeNil)
+	})
+
+	Convey("Can recover range deletions from the log", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			for _, v := range s {
+				c.Put(1, []byte(v), []byte(v))
+			}
+			c.CutPrefix([]byte("/test/one/"))
+			c.CutRange([]byte("/test/zen/sub-two"), nil)
+			c.DelPrefix(2, []byte("/test/two/"))
+			c.DelRange(3, []byte(""), []byte("/test/"))
+			return nil
+		}), ShouldBeNil)
+		want := dump(db.Load())
+		So(db.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Load().Size(), ShouldEqual, 17)
+		So(dump(db.Load()), ShouldResemble, want)
+		So(db.Close(), ShouldBeNil)
+	})
+
+	Convey("Can discard a torn record at the end of the log", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		for _, v := range []string{"ONE", "TWO", "TRI"} {
+			val := []byte(v)
+			So(db.Update(func(c *Copy[[]byte]) error {
+				c.Put(0, []byte("/"+v), val)
+				return nil
+			}), ShouldBeNil)
+		}
+		So(db.Close(), ShouldBeNil)
+		wal := filepath.Join(dir, "wal")
+		info, err := os.Stat(wal)
+		So(err, ShouldBeNil)
+		So(os.Truncate(wal, info.Size()-3), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Load().Size(), ShouldEqual, 2)
+		So(db.Load().Copy().Get(0, []byte("/TRI")), ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(0, []byte("/FOR"), []byte("FOR"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Load().Size(), ShouldEqual, 3)
+		So(db.Load().Copy().Get(0, []byte("/FOR")), ShouldResemble, []byte("FOR"))
+		So(db.Close(), ShouldBeNil)
+	})
+
+	Convey("Can discard a corrupt record at the end of the log", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(0, []byte("/test"), []byte("ONE"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+		f, err := os.OpenFile(filepath.Join(dir, "wal"), os.O_WRONLY|os.O_APPEND, 0644)
+		So(err, ShouldBeNil)
+		_, err = f.Write([]byte{0, 0, 0, 4, 1, 2, 3, 4, 5, 6, 7, 8})
+		So(err, ShouldBeNil)
+		So(f.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Load().Size(), ShouldEqual, 1)
+		So(db.Close(), ShouldBeNil)
+	})
+
+	Convey("Can not discard commits after a corrupt record in the log", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		for _, v := range []string{"ONE", "TWO", "TRI"} {
+			val := []byte(v)
+			So(db.Update(func(c *Copy[[]byte]) error {
+				c.Put(0, []byte("/"+v), val)
+				return nil
+			}), ShouldBeNil)
+		}
+		So(db.Close(), ShouldBeNil)
+		wal := filepath.Join(dir, "wal")
+		data, err := os.ReadFile(wal)
+		So(err, ShouldBeNil)
+		one := 8 + int(binary.BigEndian.Uint32(data))
+		two := one + 8 + int(binary.BigEndian.Uint32(data[one:]))
+		data[two-1] ^= 0x01
+		So(os.WriteFile(wal, data, 0644), ShouldBeNil)
+		_, err = Open(dir)
+		So(err, ShouldEqual, ErrLogFormat)
+		info, err := os.Stat(wal)
+		So(err, ShouldBeNil)
+		So(info.Size(), ShouldEqual, len(data))
+	})
+
+	Convey("Can checkpoint and truncate the log", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/test"), []byte("ONE"))
+			c.Put(1, []byte("/cut"), []byte("CUT"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Checkpoint(), ShouldBeNil)
+		info, err := os.Stat(filepath.Join(dir, "wal"))
+		So(err, ShouldBeNil)
+		So(info.Size(), ShouldEqual, 0)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(2, []byte("/test"), []byte("TWO"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Checkpoint(), ShouldBeNil)
+		snaps, _ := filepath.Glob(filepath.Join(dir, "*.snap"))
+		So(snaps, ShouldHaveLength, 1)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Cut([]byte("/cut"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		c := db.Load().Copy()
+		So(c.Size(), ShouldEqual, 1)
+		So(c.Get(1, []byte("/test")), ShouldResemble, []byte("ONE"))
+		So(c.Get(2, []byte("/test")), ShouldResemble, []byte("TWO"))
+		So(c.Get(1, []byte("/cut")), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+	})
+
+	Convey("Can skip commits already contained in a snapshot", t, func() {
+		dir := t.TempDir()
+		db, err := Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/test"), []byte("ONE"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Cut([]byte("/test"))
+			c.Put(2, []byte("/test"), []byte("TWO"))
+			return nil
+		}), ShouldBeNil)
+		wal, err := os.ReadFile(filepath.Join(dir, "wal"))
+		So(err, ShouldBeNil)
+		So(db.Checkpoint(), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+		// Simulate a crash before the log was truncated
+		So(os.WriteFile(filepath.Join(dir, "wal"), wal, 0644), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "0000000000000009.snap.tmp"), wal, 0644), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Load().Copy().Get(1, []byte("/test")), ShouldBeNil)
+		So(db.Load().Copy().Get(2, []byte("/test")), ShouldResemble, []byte("TWO"))
+		So(db.Update(func(c *Copy[[]byte]) error {
+			c.Put(3, []byte("/test"), []byte("TRI"))
+			return nil
+		}), ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+		db, err = Open(dir)
+		So(err, ShouldBeNil)
+		So(db.Load().Copy().Get(3, []byte("/test")), ShouldResemble, []byte("TRI"))
+		So(db.Close(), ShouldBeNil)
+		tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+		So(tmps, ShouldBeEmpty)
+	})
+
+}
@@ -0,0 +1,103 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"context"
+)
+
+// watch is a channel which is closed once the subtree of a prefix is
+// no longer the same node as it was in the tree which was watched.
+type watch[V any] struct {
+	pre  []byte
+	node *Node[V]
+	ch   chan struct{}
+}
+
+// Watch returns a channel which is closed once a tree committed to the
+// store after this tree changes any node on the path to the prefix, or
+// within its subtree. Changes are detected by comparing the identity of
+// the node which contains the keys with the prefix, so a commit may
+// occasionally close the channel without changing any of those keys. If
+// this tree was not loaded from a Store or DB, then the returned channel
+// is nil, and so is never closed. The returned cancel function removes
+// the watch from the store, and must be called once the channel is no
+// longer needed, unless the channel has been closed.
+func (t *Tree[V]) Watch(prefix []byte) (<-chan struct{}, func()) {
+	if t.store == nil {
+		return nil, func() {}
+	}
+	w := t.store.watch(t, prefix)
+	return w.ch, func() {
+		t.store.unwatch(w)
+	}
+}
+
+// WaitChanged blocks until a tree committed to the store after this tree
+// changes any node on the path to the prefix, or within its subtree, as
+// described for Watch. It returns the context error if the context is
+// done before then.
+func (t *Tree[V]) WaitChanged(ctx context.Context, prefix []byte) error {
+	if t.store == nil {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	w := t.store.watch(t, prefix)
+	select {
+	case <-w.ch:
+		return nil
+	case <-ctx.Done():
+		t.store.unwatch(w)
+		return ctx.Err()
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func (s *Store[V]) watch(t *Tree[V], prefix []byte) *watch[V] {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	w := &watch[V]{
+		pre:  append([]byte(nil), prefix...),
+		node: t.root.sub(prefix),
+		ch:   make(chan struct{}),
+	}
+	if s.Load().root.sub(w.pre) != w.node {
+		close(w.ch)
+		return w
+	}
+	if s.watches == nil {
+		s.watches = make(map[*watch[V]]struct{})
+	}
+	s.watches[w] = struct{}{}
+	return w
+}
+
+func (s *Store[V]) unwatch(w *watch[V]) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.watches, w)
+}
+
+// notify closes the channel of every watch whose prefix has changed in
+// the tree, and must be called while the store is locked.
+func (s *Store[V]) notify(t *Tree[V]) {
+	for w := range s.watches {
+		if t.root.sub(w.pre) != w.node {
+			delete(s.watches, w)
+			close(w.ch)
+		}
+	}
+}
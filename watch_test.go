@@ -0,0 +1,128 @@
+// Copyright © SurrealDB Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vtree
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWatch(t *testing.T) {
+
+	fired := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	put := func(st *Store[[]byte], key string) {
+		So(st.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte(key), []byte(key))
+			return nil
+		}), ShouldBeNil)
+	}
+
+	Convey("Watch fires only for changes under the prefix", t, func() {
+		st := NewStore[[]byte](nil)
+		put(st, "/test/one")
+		put(st, "/other/one")
+		tr := st.Load()
+		pre, _ := tr.Watch([]byte("/test/"))
+		all, _ := tr.Watch(nil)
+		put(st, "/other/two")
+		So(fired(pre), ShouldBeFalse)
+		So(fired(all), ShouldBeTrue)
+		put(st, "/test/two")
+		So(fired(pre), ShouldBeTrue)
+	})
+
+	Convey("Watch fires immediately for a stale tree", t, func() {
+		st := NewStore[[]byte](nil)
+		tr := st.Load()
+		put(st, "/test/one")
+		ch, _ := tr.Watch([]byte("/test/"))
+		So(fired(ch), ShouldBeTrue)
+		So(len(st.watches), ShouldEqual, 0)
+		ch, cancel := tr.Watch([]byte("/none/"))
+		defer cancel()
+		So(fired(ch), ShouldBeFalse)
+	})
+
+	Convey("Watch removes cancelled watches from the store", t, func() {
+		st := NewStore[[]byte](nil)
+		tr := st.Load()
+		var cancels []func()
+		for i := 0; i < 100; i++ {
+			_, cancel := tr.Watch([]byte("/never/"))
+			cancels = append(cancels, cancel)
+		}
+		So(len(st.watches), ShouldEqual, 100)
+		for _, cancel := range cancels {
+			cancel()
+			cancel()
+		}
+		So(len(st.watches), ShouldEqual, 0)
+		put(st, "/test/one")
+		So(len(st.watches), ShouldEqual, 0)
+	})
+
+	Convey("Watch returns nil for a tree without a store", t, func() {
+		ch, cancel := New[[]byte]().Watch(nil)
+		So(ch, ShouldBeNil)
+		cancel()
+	})
+
+	Convey("WaitChanged returns once the prefix changes", t, func() {
+		st := NewStore[[]byte](nil)
+		tr := st.Load()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			st.Update(func(c *Copy[[]byte]) error {
+				c.Put(1, []byte("/test/one"), nil)
+				return nil
+			})
+		}()
+		So(tr.WaitChanged(context.Background(), []byte("/test/")), ShouldBeNil)
+	})
+
+	Convey("WaitChanged returns the context error on timeout", t, func() {
+		st := NewStore[[]byte](nil)
+		tr := st.Load()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		So(tr.WaitChanged(ctx, []byte("/test/")), ShouldResemble, context.DeadlineExceeded)
+		So(len(st.watches), ShouldEqual, 0)
+	})
+
+	Convey("Watch works for trees loaded from a database", t, func() {
+		d, err := Open(t.TempDir())
+		So(err, ShouldBeNil)
+		defer d.Close()
+		ch, cancel := d.Load().Watch([]byte("/test/"))
+		defer cancel()
+		So(d.Update(func(c *Copy[[]byte]) error {
+			c.Put(1, []byte("/test/one"), nil)
+			return nil
+		}), ShouldBeNil)
+		So(fired(ch), ShouldBeTrue)
+	})
+
+}